@@ -12,6 +12,7 @@ require (
 	github.com/pion/rtp v1.8.24
 	github.com/pion/webrtc/v4 v4.1.6
 	github.com/yohimik/goxash3d-fwgs v0.0.0-20260119181527-dd563e429ad3
+	golang.org/x/crypto v0.43.0
 )
 
 require (
@@ -28,8 +29,8 @@ require (
 	github.com/pion/transport/v3 v3.0.8 // indirect
 	github.com/pion/turn/v4 v4.1.1 // indirect
 	github.com/wlynxg/anet v0.0.5 // indirect
-	golang.org/x/crypto v0.43.0 // indirect
 	golang.org/x/net v0.46.0 // indirect
 	golang.org/x/sys v0.37.0 // indirect
+	golang.org/x/text v0.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -1,4 +1,5 @@
 import {Net, Packet, Xash3D, Xash3DOptions} from "xash3d-fwgs";
+import {sha256} from "./sha256";
 
 export class Xash3DWebRTC extends Xash3D {
     private channel?: RTCDataChannel
@@ -23,8 +24,15 @@ export class Xash3DWebRTC extends Xash3D {
         ]);
     }
 
-    startConnection() {
-        this.peer = new RTCPeerConnection()
+    async startConnection() {
+        // offers and candidates arriving meanwhile are queued until the peer exists, connectWs clears the queue
+        const ws = this.ws
+        const iceServers = await fetchIceServers()
+        if (ws !== this.ws) {
+            // the socket reconnected while fetching, its own startConnection creates the peer
+            return
+        }
+        this.peer = new RTCPeerConnection({iceServers})
         this.peer.onicecandidate = e => {
             if (!e.candidate) {
                 return
@@ -147,6 +155,12 @@ export class Xash3DWebRTC extends Xash3D {
         if (this.ws) {
             this.ws.close()
         }
+        // drop the old peer, so signaling arriving while the new one fetches its ICE servers is queued for it
+        this.peer?.close()
+        this.peer = undefined
+        this.wasRemote = false
+        this.candidates = []
+        this.remoteDescription = undefined
         const protocol = window.location.protocol === "https:" ? "wss" : "ws";
         const host = window.location.host;
         const handler = async (e: MessageEvent) => {
@@ -162,6 +176,25 @@ export class Xash3DWebRTC extends Xash3D {
                         this.handleCandidates()
                     }
                     break
+                case 'challenge':
+                    // the session token goes in the first message rather than the URL, which ends up in access logs
+                    this.wsSend('challenge', {
+                        challenge: parsed.data.challenge,
+                        solution: await solveChallenge(parsed.data.challenge, parsed.data.bits),
+                        session: localStorage.getItem('session') ?? undefined,
+                    })
+                    break
+                case 'session':
+                    localStorage.setItem('session', parsed.data.token)
+                    break
+                case 'maintenance':
+                    if (this.timeout) {
+                        clearTimeout(this.timeout)
+                        this.timeout = undefined
+                    }
+                    document.getElementById('warning')!.textContent = parsed.data.message
+                    document.getElementById('warning')!.style.opacity = '1'
+                    break
             }
         }
         this.ws = new WebSocket(`${protocol}://${host}/websocket`);
@@ -192,4 +225,37 @@ export class Xash3DWebRTC extends Xash3D {
         if (!this.channel) return
         this.channel.send(packet.data)
     }
-}
\ No newline at end of file
+}
+
+// fetchIceServers returns the STUN/TURN servers with fresh TURN credentials, none if the server has no relays configured
+async function fetchIceServers(): Promise<RTCIceServer[]> {
+    try {
+        const res = await fetch('/v1/ice')
+        if (!res.ok) return []
+        return (await res.json()).ice_servers ?? []
+    } catch (e) {
+        return []
+    }
+}
+
+// solveChallenge finds a solution whose sha256("<challenge>:<solution>") starts with the requested zero bits.
+// WebCrypto is only available in secure contexts, over plain HTTP the slower JavaScript implementation is used.
+async function solveChallenge(challenge: string, bits: number): Promise<string> {
+    if (!bits) return ''
+    const encoder = new TextEncoder()
+    const subtle = globalThis.crypto?.subtle
+    for (let counter = 0; ; counter++) {
+        const data = encoder.encode(`${challenge}:${counter}`)
+        const digest = subtle ? new Uint8Array(await subtle.digest('SHA-256', data)) : sha256(data)
+        if (leadingZeroBits(digest) >= bits) return String(counter)
+    }
+}
+
+function leadingZeroBits(digest: Uint8Array): number {
+    let n = 0
+    for (const b of digest) {
+        if (b) return n + Math.clz32(b) - 24
+        n += 8
+    }
+    return n
+}
@@ -0,0 +1,397 @@
+// Command loadtest connects headless WebRTC clients to a running server and reports how the network path holds up.
+//
+// Every client completes the signaling handshake like the web client, sends padded connectionless "ping" packets
+// to the engine and optionally a synthetic voice track. Replies are matched to pings in order, so latency is
+// approximate once packets get dropped.
+//
+//	go run ./src/loadtest -url ws://localhost:27016/websocket -clients 32 -duration 1m
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+	"math/bits"
+	"math/rand"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pingPrefix is a connectionless engine packet, the padding is passed as an ignored argument
+var pingPrefix = []byte("\xff\xff\xff\xffping ")
+
+const (
+	voiceFrame = 20 * time.Millisecond
+	// replyTimeout is how long a ping may stay unanswered before it counts as dropped
+	replyTimeout = 2 * time.Second
+)
+
+type options struct {
+	url      string
+	clients  int
+	duration time.Duration
+	rate     int
+	size     int
+	voice    bool
+	ramp     time.Duration
+}
+
+// stats are shared by all clients
+type stats struct {
+	connected   atomic.Int64
+	failed      atomic.Int64
+	sent        atomic.Int64
+	sentBytes   atomic.Int64
+	received    atomic.Int64
+	recvBytes   atomic.Int64
+	dropped     atomic.Int64
+	voiceFrames atomic.Int64
+
+	lock        sync.Mutex
+	latencies   []time.Duration
+	connectTime []time.Duration
+}
+
+func (s *stats) addLatency(d time.Duration) {
+	s.lock.Lock()
+	s.latencies = append(s.latencies, d)
+	s.lock.Unlock()
+}
+
+func (s *stats) addConnectTime(d time.Duration) {
+	s.lock.Lock()
+	s.connectTime = append(s.connectTime, d)
+	s.lock.Unlock()
+}
+
+type signalingMessage struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// client is one headless player
+type client struct {
+	opts  options
+	stats *stats
+
+	wsLock sync.Mutex
+	ws     *websocket.Conn
+	peer   *webrtc.PeerConnection
+
+	pendingLock sync.Mutex
+	pending     []time.Time // send times of unanswered pings, oldest first
+}
+
+func (c *client) send(event string, data any) error {
+	c.wsLock.Lock()
+	defer c.wsLock.Unlock()
+	return c.ws.WriteJSON(map[string]any{"event": event, "data": data})
+}
+
+// run connects, exchanges traffic until stop is closed and disconnects
+func (c *client) run(stop <-chan struct{}) error {
+	started := time.Now()
+	ws, _, err := websocket.DefaultDialer.Dial(c.opts.url, nil)
+	if err != nil {
+		return fmt.Errorf("websocket: %w", err)
+	}
+	defer ws.Close()
+	c.ws = ws
+
+	peer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return err
+	}
+	defer peer.Close()
+	c.peer = peer
+
+	var voice *webrtc.TrackLocalStaticSample
+	if c.opts.voice {
+		voice, err = webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", "loadtest")
+		if err != nil {
+			return err
+		}
+		if _, err := peer.AddTrack(voice); err != nil {
+			return err
+		}
+	}
+
+	peer.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate != nil {
+			c.send("candidate", candidate.ToJSON())
+		}
+	})
+	peer.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := track.Read(buf); err != nil {
+				return
+			}
+		}
+	})
+
+	// the server writes on "write" and reads from "read"
+	ready := make(chan *webrtc.DataChannel, 1)
+	peer.OnDataChannel(func(channel *webrtc.DataChannel) {
+		switch channel.Label() {
+		case "write":
+			channel.OnMessage(c.received)
+		case "read":
+			channel.OnOpen(func() { ready <- channel })
+		}
+	})
+
+	signalingErr := make(chan error, 1)
+	go func() { signalingErr <- c.signal() }()
+
+	var channel *webrtc.DataChannel
+	select {
+	case channel = <-ready:
+	case err := <-signalingErr:
+		return err
+	case <-time.After(30 * time.Second):
+		return fmt.Errorf("data channels didn't open within 30s")
+	case <-stop:
+		return nil
+	}
+	c.stats.connected.Add(1)
+	c.stats.addConnectTime(time.Since(started))
+	defer c.stats.connected.Add(-1)
+
+	if voice != nil {
+		go c.sendVoice(voice, stop)
+	}
+	return c.sendPings(channel, stop, signalingErr)
+}
+
+// signal answers the server's offers and exchanges ICE candidates until the websocket closes
+func (c *client) signal() error {
+	for {
+		message := signalingMessage{}
+		if err := c.ws.ReadJSON(&message); err != nil {
+			return fmt.Errorf("signaling: %w", err)
+		}
+		switch message.Event {
+		case "challenge":
+			challenge := struct {
+				Challenge string `json:"challenge"`
+				Bits      int    `json:"bits"`
+			}{}
+			if err := json.Unmarshal(message.Data, &challenge); err != nil {
+				return err
+			}
+			solution := solveChallenge(challenge.Challenge, challenge.Bits)
+			if err := c.send("challenge", map[string]string{"challenge": challenge.Challenge, "solution": solution}); err != nil {
+				return err
+			}
+		case "offer":
+			offer := webrtc.SessionDescription{}
+			if err := json.Unmarshal(message.Data, &offer); err != nil {
+				return err
+			}
+			if err := c.peer.SetRemoteDescription(offer); err != nil {
+				return err
+			}
+			answer, err := c.peer.CreateAnswer(nil)
+			if err != nil {
+				return err
+			}
+			if err := c.peer.SetLocalDescription(answer); err != nil {
+				return err
+			}
+			if err := c.send("answer", answer); err != nil {
+				return err
+			}
+		case "candidate":
+			candidate := webrtc.ICECandidateInit{}
+			if err := json.Unmarshal(message.Data, &candidate); err != nil {
+				return err
+			}
+			if err := c.peer.AddICECandidate(candidate); err != nil {
+				return err
+			}
+		case "maintenance":
+			return fmt.Errorf("server is in maintenance mode: %s", message.Data)
+		}
+	}
+}
+
+// solveChallenge finds a solution whose sha256("<challenge>:<solution>") starts with the requested zero bits
+func solveChallenge(challenge string, zeroBits int) string {
+	if zeroBits <= 0 {
+		return ""
+	}
+	for counter := 0; ; counter++ {
+		solution := fmt.Sprint(counter)
+		digest := sha256.Sum256([]byte(challenge + ":" + solution))
+		n := 0
+		for _, b := range digest {
+			n += bits.LeadingZeros8(b)
+			if b != 0 {
+				break
+			}
+		}
+		if n >= zeroBits {
+			return solution
+		}
+	}
+}
+
+// sendPings sends padded pings at the configured rate and expires unanswered ones
+func (c *client) sendPings(channel *webrtc.DataChannel, stop <-chan struct{}, signalingErr <-chan error) error {
+	packet := append(slices.Clone(pingPrefix), bytes.Repeat([]byte("x"), max(c.opts.size-len(pingPrefix), 0))...)
+	ticker := time.NewTicker(time.Second / time.Duration(c.opts.rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-signalingErr:
+			return err
+		case <-ticker.C:
+		}
+
+		c.expire()
+		c.pendingLock.Lock()
+		c.pending = append(c.pending, time.Now())
+		c.pendingLock.Unlock()
+		if err := channel.Send(packet); err != nil {
+			return fmt.Errorf("data channel: %w", err)
+		}
+		c.stats.sent.Add(1)
+		c.stats.sentBytes.Add(int64(len(packet)))
+	}
+}
+
+// received matches an engine reply to the oldest unanswered ping
+func (c *client) received(msg webrtc.DataChannelMessage) {
+	c.stats.received.Add(1)
+	c.stats.recvBytes.Add(int64(len(msg.Data)))
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	if len(c.pending) > 0 {
+		c.stats.addLatency(time.Since(c.pending[0]))
+		c.pending = c.pending[1:]
+	}
+}
+
+// expire counts pings without a reply for too long as dropped
+func (c *client) expire() {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	expired := 0
+	for expired < len(c.pending) && time.Since(c.pending[expired]) > replyTimeout {
+		expired++
+	}
+	c.pending = c.pending[expired:]
+	c.stats.dropped.Add(int64(expired))
+}
+
+// sendVoice streams random frames the size of a typical voice packet, the SFU forwards them without decoding
+func (c *client) sendVoice(track *webrtc.TrackLocalStaticSample, stop <-chan struct{}) {
+	ticker := time.NewTicker(voiceFrame)
+	defer ticker.Stop()
+	frame := make([]byte, 80)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		rand.Read(frame)
+		if err := track.WriteSample(media.Sample{Data: frame, Duration: voiceFrame}); err != nil {
+			return
+		}
+		c.stats.voiceFrames.Add(1)
+	}
+}
+
+// percentile returns the p-th percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[min(int(float64(len(sorted))*p), len(sorted)-1)]
+}
+
+func (s *stats) report(elapsed time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	slices.Sort(s.latencies)
+	slices.Sort(s.connectTime)
+
+	sent, received, dropped := s.sent.Load(), s.received.Load(), s.dropped.Load()
+	seconds := elapsed.Seconds()
+	fmt.Printf("clients:      %d connected, %d failed\n", len(s.connectTime), s.failed.Load())
+	fmt.Printf("connect time: p50 %v, p95 %v, max %v\n",
+		percentile(s.connectTime, 0.5), percentile(s.connectTime, 0.95), percentile(s.connectTime, 1))
+	fmt.Printf("packets:      %d sent (%.0f/s, %.1f KB/s), %d received (%.0f/s, %.1f KB/s)\n",
+		sent, float64(sent)/seconds, float64(s.sentBytes.Load())/seconds/1024,
+		received, float64(received)/seconds, float64(s.recvBytes.Load())/seconds/1024)
+	if sent > 0 {
+		fmt.Printf("dropped:      %d (%.2f%%)\n", dropped, float64(dropped)/float64(sent)*100)
+	}
+	fmt.Printf("latency:      p50 %v, p95 %v, p99 %v\n",
+		percentile(s.latencies, 0.5), percentile(s.latencies, 0.95), percentile(s.latencies, 0.99))
+	if frames := s.voiceFrames.Load(); frames > 0 {
+		fmt.Printf("voice:        %d frames sent\n", frames)
+	}
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.url, "url", "ws://localhost:27016/websocket", "signaling WebSocket URL of the server")
+	flag.IntVar(&opts.clients, "clients", 8, "number of clients")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "how long to send traffic")
+	flag.IntVar(&opts.rate, "rate", 30, "packets per second per client")
+	flag.IntVar(&opts.size, "size", 128, "packet size in bytes")
+	flag.BoolVar(&opts.voice, "voice", false, "send a synthetic voice track from every client")
+	flag.DurationVar(&opts.ramp, "ramp", 5*time.Second, "spread client connections over this long")
+	flag.Parse()
+	if opts.clients < 1 || opts.rate < 1 {
+		fmt.Fprintln(os.Stderr, "-clients and -rate must be positive")
+		os.Exit(2)
+	}
+
+	s := &stats{}
+	stop := make(chan struct{})
+	var clients sync.WaitGroup
+	started := time.Now()
+	for i := 0; i < opts.clients; i++ {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			time.Sleep(opts.ramp * time.Duration(i) / time.Duration(opts.clients))
+			c := &client{opts: opts, stats: s}
+			if err := c.run(stop); err != nil {
+				s.failed.Add(1)
+				fmt.Fprintf(os.Stderr, "client %d: %v\n", i, err)
+			}
+		}()
+	}
+
+	progress := time.NewTicker(5 * time.Second)
+	defer progress.Stop()
+	deadline := time.After(opts.duration)
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-progress.C:
+			fmt.Fprintf(os.Stderr, "%v: %d connected, %d sent, %d received\n",
+				time.Since(started).Round(time.Second), s.connected.Load(), s.sent.Load(), s.received.Load())
+		}
+	}
+	close(stop)
+	clients.Wait()
+	s.report(time.Since(started))
+}
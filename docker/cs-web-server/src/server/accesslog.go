@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/pion/logging"
+	"io"
+	mathrand "math/rand"
+	gonet "net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// accessLog always logs at info level, independently of PION_LOG_* settings
+var accessLog = logging.NewDefaultLeveledLoggerForScope("http", logging.LogLevelInfo, os.Stderr)
+
+type requestIDKey struct{}
+
+// requestID returns the ID assigned to a request by the access log middleware
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// accessLogging assigns every request an ID and logs it once it's done.
+// Excluded paths are never logged, sampled paths only at the configured rate unless they failed.
+func accessLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		cfg := appConfig().AccessLog
+		if !cfg.Enabled || matchesPathPattern(r.URL.Path, cfg.Exclude) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status < http.StatusBadRequest && matchesPathPattern(r.URL.Path, cfg.Sampled) &&
+			mathrand.Float64() >= cfg.SampleRate { //nolint: gosec
+			return
+		}
+		accessLog.Infof("method=%s path=%q status=%d bytes=%d duration=%s ip=%s request_id=%s",
+			r.Method, r.URL.Path, recorder.statusCode(), recorder.written, time.Since(start).Round(time.Microsecond),
+			clientIP(r), id)
+	})
+}
+
+// matchesPathPattern reports whether path equals one of the comma-separated patterns,
+// or starts with one ending in a slash
+func matchesPathPattern(path, patterns string) bool {
+	for _, pattern := range sliceArgs(patterns) {
+		if path == pattern || (strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the connecting client without the port
+func clientIP(r *http.Request) string {
+	host, _, err := gonet.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// validRequestID accepts request IDs set by a proxy if they are short and printable
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (s *statusRecorder) statusCode() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.written += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) ReadFrom(r io.Reader) (int64, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := io.Copy(s.ResponseWriter, r)
+	s.written += n
+	return n, err
+}
+
+func (s *statusRecorder) Hijack() (gonet.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(s.ResponseWriter).Hijack()
+	if err == nil {
+		s.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
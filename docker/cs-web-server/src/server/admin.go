@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// requireAdmin rejects requests that don't carry the admin bearer token.
+// The admin API is disabled when neither a token nor a token hash is configured.
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := appConfig().Server
+		if cfg.AdminToken == "" && cfg.AdminTokenHash == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !validAdminToken(cfg.AdminToken, cfg.AdminTokenHash, token) {
+			adminAuthFailures.Add(1)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validAdminToken compares token with the configured token or its hash in constant time
+func validAdminToken(adminToken, adminTokenHash, token string) bool {
+	if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return true
+	}
+	if adminTokenHash == "" {
+		return false
+	}
+	salt, hash, _ := parseTokenHash(adminTokenHash) // validated on load
+	return subtle.ConstantTimeCompare(hashToken(salt, token), hash) == 1
+}
+
+// hashToken returns the SHA-256 of salt and token. Admin tokens are random secrets, so a fast hash is enough and
+// keeps the check cheap on every request.
+func hashToken(salt []byte, token string) []byte {
+	sum := sha256.Sum256(append(slices.Clone(salt), token...))
+	return sum[:]
+}
+
+// formatTokenHash returns a token hash as stored in server.admin_token_hash: "sha256:<salt hex>:<hash hex>"
+func formatTokenHash(salt []byte, token string) string {
+	return "sha256:" + hex.EncodeToString(salt) + ":" + hex.EncodeToString(hashToken(salt, token))
+}
+
+func parseTokenHash(value string) ([]byte, []byte, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 || parts[0] != "sha256" {
+		return nil, nil, fmt.Errorf(`invalid admin token hash: expected "sha256:<salt>:<hash>" from --hash-token`)
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid admin token hash salt: %w", err)
+	}
+	hash, err := hex.DecodeString(parts[2])
+	if err != nil || len(hash) != sha256.Size {
+		return nil, nil, fmt.Errorf("invalid admin token hash: expected %d hex bytes", sha256.Size)
+	}
+	return salt, hash, nil
+}
+
+// writeJSON serializes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Failed to write JSON response: %v", err)
+	}
+}
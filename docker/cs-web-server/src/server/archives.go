@@ -0,0 +1,210 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const archivesPrefix = "/content/"
+
+// archiveExtensions are the archive types mounted from the archive directory
+var archiveExtensions = []string{".zip", ".pk3"}
+
+// mountedArchive is an opened archive, reopened when the file changes on disk
+type mountedArchive struct {
+	reader  *zip.ReadCloser
+	files   map[string]*zip.File
+	modTime time.Time
+	size    int64
+}
+
+var (
+	archivesLock sync.Mutex
+	archives     = map[string]*mountedArchive{}
+)
+
+// archiveMounts returns the mount name to archive path of all configured archives.
+// Archives dropped into the archive directory are picked up on the next request.
+func archiveMounts() map[string]string {
+	mounts := map[string]string{}
+	if dir := appConfig().Content.ArchiveDir; dir != "" {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			extension := strings.ToLower(filepath.Ext(entry.Name()))
+			if entry.IsDir() || !slices.Contains(archiveExtensions, extension) {
+				continue
+			}
+			mounts[strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))] = filepath.Join(dir, entry.Name())
+		}
+	}
+	for mount, file := range parseFilesMap(appConfig().Content.Archives) {
+		mounts[mount] = file
+	}
+	return mounts
+}
+
+// openArchive returns the opened archive at file, reopening it if it changed since it was last opened
+func openArchive(file string) (*mountedArchive, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	archivesLock.Lock()
+	defer archivesLock.Unlock()
+
+	if archive, ok := archives[file]; ok {
+		if archive.size == info.Size() && archive.modTime.Equal(info.ModTime()) {
+			return archive, nil
+		}
+		// not closed as responses may still be streaming from it, the file is closed once it's unreachable
+		delete(archives, file)
+	}
+
+	reader, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", file, err)
+	}
+	archive := &mountedArchive{
+		reader:  reader,
+		files:   make(map[string]*zip.File, len(reader.File)),
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+	for _, f := range reader.File {
+		if !f.FileInfo().IsDir() {
+			// archives built on Windows may use backslashes, lookups are case-insensitive like the engine's
+			archive.files[strings.ToLower(strings.ReplaceAll(f.Name, "\\", "/"))] = f
+		}
+	}
+	archives[file] = archive
+	return archive, nil
+}
+
+// archiveHandler serves members of mounted archives at /content/<mount>/<member>
+func archiveHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), strings.TrimSuffix(archivesPrefix, "/"))
+	mount, member, ok := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	if !ok || member == "" {
+		http.NotFound(w, r)
+		return
+	}
+	file, ok := archiveMounts()[mount]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	serveArchiveMember(w, r, file, member)
+}
+
+// serveArchiveMember serves a single archive member with range and conditional request support
+func serveArchiveMember(w http.ResponseWriter, r *http.Request, file, member string) {
+	archive, err := openArchive(file)
+	if err != nil {
+		log.Errorf("Failed to serve %s from archive: %v", member, err)
+		http.NotFound(w, r)
+		return
+	}
+	f, ok := archive.files[strings.ToLower(member)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	content := &archiveMemberReader{file: f, size: int64(f.UncompressedSize64)}
+	defer content.Close()
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%08x-%x"`, f.CRC32, f.UncompressedSize64))
+	w.Header().Set("Cache-Control", appConfig().Assets.CacheControl)
+	http.ServeContent(w, r, path.Base(member), f.Modified, content)
+}
+
+// archiveMemberReader is a seekable reader over an archive member.
+// Stored members are read directly from the archive, compressed ones are decompressed
+// again from the start whenever a read seeks backwards.
+type archiveMemberReader struct {
+	file   *zip.File
+	size   int64
+	offset int64 // position requested by Seek
+	read   int64 // position of the underlying reader
+	reader io.ReadCloser
+}
+
+func (a *archiveMemberReader) Read(p []byte) (int, error) {
+	if a.offset >= a.size {
+		return 0, io.EOF
+	}
+	if a.reader == nil || a.offset < a.read || (a.offset > a.read && a.file.Method == zip.Store) {
+		if err := a.reopen(); err != nil {
+			return 0, err
+		}
+	}
+	if a.offset > a.read {
+		if _, err := io.CopyN(io.Discard, a.reader, a.offset-a.read); err != nil {
+			return 0, err
+		}
+		a.read = a.offset
+	}
+	n, err := a.reader.Read(p)
+	a.read += int64(n)
+	a.offset = a.read
+	return n, err
+}
+
+func (a *archiveMemberReader) reopen() error {
+	if a.reader != nil {
+		a.reader.Close()
+	}
+	a.read = 0
+	if a.file.Method == zip.Store {
+		// stored members can be read from any offset without decompressing
+		raw, err := a.file.OpenRaw()
+		if err != nil {
+			return err
+		}
+		if readerAt, ok := raw.(io.ReaderAt); ok {
+			a.reader = io.NopCloser(io.NewSectionReader(readerAt, a.offset, a.size-a.offset))
+			a.read = a.offset
+			return nil
+		}
+	}
+	reader, err := a.file.Open()
+	if err != nil {
+		return err
+	}
+	a.reader = reader
+	return nil
+}
+
+func (a *archiveMemberReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += a.offset
+	case io.SeekEnd:
+		offset += a.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	a.offset = offset
+	return offset, nil
+}
+
+func (a *archiveMemberReader) Close() error {
+	if a.reader != nil {
+		return a.reader.Close()
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// precompressed variants in order of preference
+var assetEncodings = []struct {
+	name      string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// assetHash is the content hash of a file at a given size and modification time
+type assetHash struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// assetHashes caches content hashes so multi-megabyte assets are only hashed once per change
+var assetHashes sync.Map
+
+// assetHandler serves a file from public/, preferring a precompressed variant the client accepts.
+// Responses carry a content hash ETag and the configured Cache-Control.
+func assetHandler(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+	if name == "/" {
+		name = "/index.html"
+	}
+	file := filepath.Join("public", filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Cache-Control", assetCacheControl(strings.TrimPrefix(name, "/")))
+
+	served, encoding := file, ""
+	for _, variant := range assetEncodings {
+		if !acceptsEncoding(r, variant.name) {
+			continue
+		}
+		if variantInfo, err := os.Stat(file + variant.extension); err == nil && !variantInfo.IsDir() {
+			served, encoding, info = file+variant.extension, variant.name, variantInfo
+			break
+		}
+	}
+	if hasAssetVariants(file) {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	f, err := os.Open(served)
+	if err != nil {
+		http.Error(w, "failed to open file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	hash, err := contentHash(served, info, f)
+	if err != nil {
+		http.Error(w, "failed to read file", http.StatusInternalServerError)
+		return
+	}
+	header.Set("ETag", `"`+hash+`"`)
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+
+	// the original name keeps the content type of the uncompressed file
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// assetCacheControl returns the Cache-Control value for a file relative to public/
+func assetCacheControl(name string) string {
+	for _, pattern := range sliceArgs(appConfig().Assets.Immutable) {
+		if matched, _ := path.Match(pattern, name); matched {
+			return immutableCacheControl
+		}
+	}
+	return appConfig().Assets.CacheControl
+}
+
+// acceptsEncoding reports whether the request's Accept-Encoding lists the encoding
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, value := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		if strings.TrimSpace(name) != encoding {
+			continue
+		}
+		// q=0 explicitly refuses the encoding
+		return strings.ReplaceAll(params, " ", "") != "q=0"
+	}
+	return false
+}
+
+// hasAssetVariants reports whether a file has any precompressed variant
+func hasAssetVariants(file string) bool {
+	for _, variant := range assetEncodings {
+		if _, err := os.Stat(file + variant.extension); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// contentHash returns the cached hash of the file, hashing it again if it changed on disk
+func contentHash(file string, info os.FileInfo, f io.ReadSeeker) (string, error) {
+	if cached, ok := assetHashes.Load(file); ok {
+		cached := cached.(assetHash)
+		if cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+			return cached.hash, nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))[:32]
+	assetHashes.Store(file, assetHash{size: info.Size(), modTime: info.ModTime(), hash: hash})
+	return hash, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	gonet "net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// banFiles are the files the engine execs on start (configs/valve/default.cfg), by ban command
+var banFiles = map[string]string{
+	"addip": "listip.cfg",
+	"banid": "banned.cfg",
+}
+
+// banEntry is a ban as the engine writes it with writeip and writeid: "addip <minutes> <ip>" or "banid <minutes> <id>"
+type banEntry struct {
+	command string
+	minutes string
+	target  string
+}
+
+func (b banEntry) String() string {
+	return b.command + " " + b.minutes + " " + b.target
+}
+
+// parseBans reads bans in the engine's format, like listip.cfg and banned.cfg of another server,
+// or a bare IPv4 address or SteamID per line, which is banned permanently
+func parseBans(r io.Reader) ([]banEntry, error) {
+	var bans []banEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "//") {
+			continue
+		}
+		fields := strings.Fields(text)
+		switch {
+		case len(fields) == 3 && banFiles[fields[0]] != "":
+			bans = append(bans, banEntry{fields[0], fields[1], fields[2]})
+		case len(fields) == 1 && isIPv4(fields[0]):
+			bans = append(bans, banEntry{"addip", "0", fields[0]})
+		case len(fields) == 1 && (strings.HasPrefix(fields[0], "STEAM_") || strings.HasPrefix(fields[0], "VALVE_")):
+			bans = append(bans, banEntry{"banid", "0", fields[0]})
+		default:
+			return nil, fmt.Errorf("line %d: expected addip or banid, an IPv4 address or a SteamID: %q", line, text)
+		}
+	}
+	return bans, scanner.Err()
+}
+
+func isIPv4(text string) bool {
+	ip := gonet.ParseIP(text)
+	return ip != nil && ip.To4() != nil
+}
+
+// banFile returns the ban file the engine execs: the game directory's, falling back to valve's like the engine does
+func banFile(baseDir, gameDir, name string) string {
+	for _, dir := range []string{gameDir, "valve"} {
+		path := filepath.Join(baseDir, dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(baseDir, gameDir, name)
+}
+
+// importBans appends the bans not present yet to the engine's ban files and returns how many were added per file
+func importBans(baseDir, gameDir string, bans []banEntry) (map[string]int, error) {
+	added := map[string]int{}
+	for command, name := range banFiles {
+		path := banFile(baseDir, gameDir, name)
+		existing, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return added, err
+		}
+		// lines the engine doesn't understand either are skipped rather than refusing the import
+		present := map[string]bool{}
+		for _, line := range strings.Split(string(existing), "\n") {
+			if fields := strings.Fields(line); len(fields) == 3 && fields[0] == command {
+				present[fields[2]] = true
+			}
+		}
+
+		var lines []string
+		for _, ban := range bans {
+			if ban.command != command || present[ban.target] {
+				continue
+			}
+			present[ban.target] = true
+			lines = append(lines, ban.String())
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		content := strings.Join(lines, "\n") + "\n"
+		if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+			content = "\n" + content
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return added, err
+		}
+		_, err = file.WriteString(content)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return added, err
+		}
+		added[path] = len(lines)
+	}
+	return added, nil
+}
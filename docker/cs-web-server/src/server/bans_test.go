@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseBans(t *testing.T) {
+	bans, err := parseBans(strings.NewReader("// exported\naddip 0.0 203.0.113.7\nbanid 60 STEAM_0:1:1234\n\n198.51.100.1\nVALVE_0:0:1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"addip 0.0 203.0.113.7", "banid 60 STEAM_0:1:1234", "addip 0 198.51.100.1", "banid 0 VALVE_0:0:1"}
+	if len(bans) != len(want) {
+		t.Fatalf("got %v, want %v", bans, want)
+	}
+	for i, ban := range bans {
+		if ban.String() != want[i] {
+			t.Errorf("ban %d: got %q, want %q", i, ban, want[i])
+		}
+	}
+
+	for _, line := range []string{"kick player", "addip 0", "2001:db8::1", "banid 0 STEAM_0:1:1 extra"} {
+		if _, err := parseBans(strings.NewReader(line)); err == nil {
+			t.Errorf("parseBans(%q) succeeded", line)
+		}
+	}
+}
+
+func TestImportBansKeepsExistingBans(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{"valve", "cstrike"} {
+		if err := os.Mkdir(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the engine falls back to valve's file when the game directory has none
+	listip := filepath.Join(base, "valve", "listip.cfg")
+	if err := os.WriteFile(listip, []byte("addip 0.0 203.0.113.7"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bans := []banEntry{{"addip", "0", "203.0.113.7"}, {"addip", "0", "198.51.100.1"}, {"banid", "0", "STEAM_0:1:1234"}}
+	added, err := importBans(base, "cstrike", bans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	banned := filepath.Join(base, "cstrike", "banned.cfg")
+	if added[listip] != 1 || added[banned] != 1 {
+		t.Fatalf("unexpected additions %v", added)
+	}
+	if data, _ := os.ReadFile(listip); string(data) != "addip 0.0 203.0.113.7\naddip 0 198.51.100.1\n" {
+		t.Fatalf("unexpected listip.cfg %q", data)
+	}
+
+	if added, err := importBans(base, "cstrike", bans); err != nil || len(added) != 0 {
+		t.Fatalf("second import added %v, %v", added, err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCaptureSeconds = 30
+	maxCaptureSeconds     = 300
+	// captureBuffer is how many packets may wait for the download, further packets are left out of the capture
+	captureBuffer = 4096
+)
+
+type captureRequest struct {
+	Peer    string `json:"peer"`
+	Seconds int    `json:"seconds"`
+}
+
+// capturedPacket is one line of a capture
+type capturedPacket struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"` // "in" from the player to the engine, "out" from the engine to the player
+	Data      []byte    `json:"data"`
+}
+
+// packetCapture collects the packets of a peer for a capture download
+type packetCapture struct {
+	packets chan capturedPacket
+}
+
+// captured adds a packet to the peer's capture if one is running.
+// It's called from the engine thread and the read loop, so it never blocks on the download.
+func (s *peerSlot) captured(direction string, data []byte) {
+	capture := s.capture.Load()
+	if capture == nil {
+		return
+	}
+	select {
+	case capture.packets <- capturedPacket{Time: time.Now(), Direction: direction, Data: slices.Clone(data)}:
+	default:
+	}
+}
+
+// captureHandler records the packets of a peer for the requested seconds and streams them as JSON lines:
+// POST /v1/debug/capture {"peer": "10.1.2.3:1004", "seconds": 30}
+func captureHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := captureRequest{Seconds: defaultCaptureSeconds}
+	if err := decodeJSON(w, r, &req); err != nil && err != io.EOF {
+		writeBodyError(w, err)
+		return
+	}
+	if req.Seconds < 1 || req.Seconds > maxCaptureSeconds {
+		http.Error(w, fmt.Sprintf("seconds must be between 1 and %d", maxCaptureSeconds), http.StatusBadRequest)
+		return
+	}
+	address, err := netip.ParseAddrPort(req.Peer)
+	if err != nil || !address.Addr().Is4() {
+		http.Error(w, "peer must be the engine address of a player, like 10.1.2.3:1004", http.StatusBadRequest)
+		return
+	}
+	slot := peers.slot(engineAddr{IP: address.Addr().As4(), Port: address.Port()})
+	if slot == nil {
+		http.Error(w, "peer not found", http.StatusNotFound)
+		return
+	}
+	capture := &packetCapture{packets: make(chan capturedPacket, captureBuffer)}
+	if !slot.capture.CompareAndSwap(nil, capture) {
+		http.Error(w, "peer is already being captured", http.StatusConflict)
+		return
+	}
+	defer slot.capture.CompareAndSwap(capture, nil)
+
+	log.Infof("Capturing packets of %s for %ds", req.Peer, req.Seconds)
+	w.Header().Set("Content-Type", "application/jsonl")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="capture-%s-%s.jsonl"`,
+		strings.ReplaceAll(req.Peer, ":", "-"), time.Now().Format(logFileTimeFormat)))
+	encoder := json.NewEncoder(w)
+	deadline := time.After(time.Duration(req.Seconds) * time.Second)
+	for {
+		select {
+		case packet := <-capture.packets:
+			if err := encoder.Encode(packet); err != nil {
+				return
+			}
+		case <-deadline:
+			// stop capturing and write the packets that are still queued
+			slot.capture.CompareAndSwap(capture, nil)
+			for len(capture.packets) > 0 {
+				if err := encoder.Encode(<-capture.packets); err != nil {
+					return
+				}
+			}
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
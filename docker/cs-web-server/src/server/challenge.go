@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// challengeTimeout is how long a client has to answer the challenge before the websocket is closed
+const challengeTimeout = 10 * time.Second
+
+type challengeRequest struct {
+	Challenge string `json:"challenge"`
+	// Bits is the number of leading zero bits of sha256("<challenge>:<solution>"), 0 only asks for the challenge back
+	Bits int `json:"bits"`
+}
+
+type challengeResponse struct {
+	Challenge string `json:"challenge"`
+	Solution  string `json:"solution"`
+	// Session is the token of an earlier connection, sent here rather than in the URL so it stays out of access logs
+	Session string `json:"session,omitempty"`
+}
+
+// challengeClient makes the client answer a challenge before it gets an engine slot and a PeerConnection,
+// so clients that only open websockets can't exhaust the slots. It returns the client's session token.
+func challengeClient(c *threadSafeWriter) (string, error) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	request := challengeRequest{Challenge: hex.EncodeToString(nonce), Bits: appConfig().Signaling.ChallengeBits}
+	if err := c.WriteJSON("challenge", request); err != nil {
+		return "", err
+	}
+
+	if err := c.SetReadDeadline(time.Now().Add(challengeTimeout)); err != nil {
+		return "", err
+	}
+	message := websocketMessage{}
+	if err := c.ReadJSON(&message); err != nil {
+		return "", err
+	}
+	if err := c.SetReadDeadline(time.Time{}); err != nil {
+		return "", err
+	}
+	response, err := verifyChallenge(request, message)
+	return response.Session, err
+}
+
+// verifyChallenge checks the client's answer to the challenge request
+func verifyChallenge(request challengeRequest, message websocketMessage) (challengeResponse, error) {
+	response := challengeResponse{}
+	if message.Event != "challenge" {
+		return response, fmt.Errorf("expected challenge response, got %q", message.Event)
+	}
+	if err := json.Unmarshal(message.Data, &response); err != nil {
+		return response, err
+	}
+	if response.Challenge != request.Challenge {
+		return response, fmt.Errorf("challenge mismatch")
+	}
+	if request.Bits > 0 && leadingZeroBits(sha256.Sum256([]byte(request.Challenge+":"+response.Solution))) < request.Bits {
+		return response, fmt.Errorf("invalid proof of work")
+	}
+	return response, nil
+}
+
+func leadingZeroBits(digest [sha256.Size]byte) int {
+	n := 0
+	for _, b := range digest {
+		n += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return n
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	gonet "net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// checkResult is a single finding of the self-check.
+// Fatal problems prevent the server from starting.
+type checkResult struct {
+	name  string
+	err   error
+	fatal bool
+}
+
+// selfCheck reports on the loaded configuration cfg (configErr is the error loading it), game content,
+// client assets and ports, writes a report to w and returns false if a fatal problem was found.
+// Passing checks are reported only if verbose.
+func selfCheck(w io.Writer, cfg *Config, configErr error, verbose bool) bool {
+	results := runChecks(cfg, configErr)
+
+	ok := true
+	problems := 0
+	for _, result := range results {
+		if result.err == nil {
+			if verbose {
+				fmt.Fprintf(w, "[ OK ] %s\n", result.name)
+			}
+			continue
+		}
+		problems++
+		severity := "WARN"
+		if result.fatal {
+			severity = "FAIL"
+			ok = false
+		}
+		fmt.Fprintf(w, "[%s] %s: %v\n", severity, result.name, result.err)
+	}
+	if verbose || problems > 0 {
+		fmt.Fprintf(w, "%d checks, %d problems\n", len(results), problems)
+	}
+	return ok
+}
+
+func runChecks(cfg *Config, configErr error) []checkResult {
+	var results []checkResult
+	add := func(name string, err error, fatal bool) {
+		results = append(results, checkResult{name: name, err: err, fatal: fatal})
+	}
+
+	if configErr != nil {
+		add("configuration", configErr, true)
+		// configor stops at the first missing value, list all of them
+		for _, env := range missingRequiredEnv(reflect.ValueOf(cfg).Elem()) {
+			add("env "+env, fmt.Errorf("required but not set"), true)
+		}
+		return results
+	}
+	add("configuration", nil, true)
+
+	baseDir := engineBaseDir()
+	add("game dir valve", checkDir(filepath.Join(baseDir, "valve")), true)
+	if cfg.Engine.GameDir != "valve" {
+		add("game dir "+cfg.Engine.GameDir, checkDir(filepath.Join(baseDir, cfg.Engine.GameDir)), true)
+	}
+	add("liblist.gam", checkFile(filepath.Join(baseDir, cfg.Engine.GameDir, "liblist.gam")), true)
+
+	assets := []struct{ name, path string }{
+		{"client page", "index.html"},
+		{"valve.zip", "valve.zip"},
+		{"CLIENT_WASM_PATH", cfg.Libraries.Client},
+		{"SERVER_WASM_PATH", cfg.Libraries.Server},
+		{"MENU_WASM_PATH", cfg.Libraries.Menu},
+		{"EXTRAS_PATH", cfg.Libraries.Extras},
+		{"FILESYSTEM_WASM_PATH", cfg.Libraries.Filesystem},
+	}
+	for _, asset := range assets {
+		add(asset.name, checkFile(filepath.Join("public", asset.path)), false)
+	}
+
+	for _, listener := range listenerConfigs(cfg) {
+		add("http listen "+listener.Address, checkListenAddress(listener.Address), true)
+	}
+	if tlsEnabled(cfg) {
+		_, _, err := newTLSConfig(cfg)
+		add("tls", err, true)
+	}
+	if address := cfg.TLS.Autocert.HTTPAddress; address != "" && cfg.TLS.Autocert.Domains != "" {
+		add("http listen "+address, checkTCPPort(address), true)
+	}
+	if port := cfg.Network.Port; port != 0 {
+		add(fmt.Sprintf("ice udp port %d", port), checkUDPPort(port), true)
+	}
+
+	return results
+}
+
+// engineBaseDir returns the directory the engine finds the game directories in
+func engineBaseDir() string {
+	if dir, ok := os.LookupEnv("XASH3D_BASEDIR"); ok {
+		return dir
+	}
+	return "."
+}
+
+// missingRequiredEnv lists env vars of required config fields that are neither set nor loaded from the config file
+func missingRequiredEnv(v reflect.Value) []string {
+	var missing []string
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+		if value.Kind() == reflect.Struct {
+			missing = append(missing, missingRequiredEnv(value)...)
+			continue
+		}
+		env := field.Tag.Get("env")
+		if field.Tag.Get("required") != "true" || env == "" {
+			continue
+		}
+		if os.Getenv(env) == "" && value.IsZero() {
+			missing = append(missing, env)
+		}
+	}
+	return missing
+}
+
+func checkDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	return nil
+}
+
+func checkFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return nil
+}
+
+// checkListenAddress checks a listener address, for a unix socket only its directory so a running server's
+// socket isn't replaced
+func checkListenAddress(address string) error {
+	if path, ok := strings.CutPrefix(address, unixAddressPrefix); ok {
+		return checkDir(filepath.Dir(path))
+	}
+	return checkTCPPort(address)
+}
+
+func checkTCPPort(address string) error {
+	l, err := gonet.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+func checkUDPPort(port int) error {
+	c, err := gonet.ListenPacket("udp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
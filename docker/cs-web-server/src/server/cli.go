@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// cliCommand is an operational task that runs instead of the server.
+// Commands are flags found anywhere in the arguments, so they can follow the engine arguments of the image's
+// entrypoint: docker run <image> --check
+type cliCommand struct {
+	flag  string
+	usage string
+	run   func() int
+}
+
+var cliCommands []cliCommand
+
+func init() {
+	cliCommands = []cliCommand{
+		{"--check", "validate the configuration, game content, assets and ports", checkCommand},
+		{"--gen-secret", "print a random secret for ADMIN_TOKEN or SIGNALING_SESSION_SECRET", genSecretCommand},
+		{"--hash-token", "read an admin token from stdin and print its hash for ADMIN_TOKEN_HASH", hashTokenCommand},
+		{"--export-openapi", "print the OpenAPI document of the HTTP API", exportOpenAPICommand},
+		{"--import-bans", "merge the bans of <file> into the engine's listip.cfg and banned.cfg", importBansCommand},
+		{"--version", "print the server, engine and Go versions", versionCommand},
+		{"--help", "list the commands", helpCommand},
+	}
+}
+
+// runCommand runs the first command found in args, it returns false when the server should start instead
+func runCommand(args []string) (int, bool) {
+	for _, command := range cliCommands {
+		if slices.Contains(args, command.flag) {
+			return command.run(), true
+		}
+	}
+	return 0, false
+}
+
+func checkCommand() int {
+	// only reports on the configuration, it's never made active
+	cfg, err := readConfig()
+	if !selfCheck(os.Stdout, cfg, err, true) {
+		return 1
+	}
+	return 0
+}
+
+func genSecretCommand() int {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	fmt.Println(hex.EncodeToString(secret))
+	return 0
+}
+
+func hashTokenCommand() int {
+	token, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	token = strings.TrimSpace(token)
+	if token == "" {
+		fmt.Fprintln(os.Stderr, "Expected the admin token on stdin")
+		return 1
+	}
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	fmt.Println(formatTokenHash(salt, token))
+	return 0
+}
+
+func exportOpenAPICommand() int {
+	if err := writeOpenAPI(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func importBansCommand() int {
+	i := slices.Index(os.Args, "--import-bans")
+	if i+1 >= len(os.Args) {
+		fmt.Fprintln(os.Stderr, "Usage: xash --import-bans <file>")
+		return 1
+	}
+	file, err := os.Open(os.Args[i+1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	bans, err := parseBans(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", os.Args[i+1], err)
+		return 1
+	}
+	// the bans go to the game directory of the configuration the server would start with
+	cfg, err := readConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	added, err := importBans(engineBaseDir(), cfg.Engine.GameDir, bans)
+	for path, count := range added {
+		fmt.Printf("%s: %d bans added\n", path, count)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to import bans:", err)
+		return 1
+	}
+	if len(added) == 0 {
+		fmt.Println("All bans are present already")
+		return 0
+	}
+	fmt.Println("The engine loads the bans when it starts")
+	return 0
+}
+
+func versionCommand() int {
+	info := buildVersion()
+	fmt.Printf("server %s", info.Version)
+	if info.Commit != "" {
+		fmt.Printf(" (%s)", info.Commit)
+	}
+	fmt.Printf("\nengine %s\ngo %s %s\n", info.Engine, info.Go, info.Platform)
+	return 0
+}
+
+func helpCommand() int {
+	fmt.Println("Usage: xash [engine arguments] [--replay <capture>]")
+	fmt.Println("       xash <command>")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, command := range cliCommands {
+		fmt.Printf("  %-16s %s\n", command.flag, command.usage)
+	}
+	return 0
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jinzhu/configor"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const defaultConfigFile = "server.yaml"
+
+// Config holds the application configuration.
+// Values are resolved in order: defaults, config file, environment variables.
+type Config struct {
+	Engine struct {
+		Arguments string `yaml:"arguments" toml:"arguments" env:"ENGINE_ARGS" required:"false"`
+		Console   string `yaml:"console" toml:"console" env:"ENGINE_CONSOLE" required:"false"`
+		GameDir   string `yaml:"game_dir" toml:"game_dir" env:"GAME_DIR" required:"true"`
+		// TickRate is the dedicated server's sys_ticrate, the engine default of 100 if 0
+		TickRate int `yaml:"tickrate" toml:"tickrate" env:"ENGINE_TICKRATE"`
+	} `yaml:"engine" toml:"engine"`
+	Libraries struct {
+		Client           string `yaml:"client" toml:"client" env:"CLIENT_WASM_PATH" required:"true"`
+		Server           string `yaml:"server" toml:"server" env:"SERVER_WASM_PATH" required:"true"`
+		Menu             string `yaml:"menu" toml:"menu" env:"MENU_WASM_PATH" required:"true"`
+		Extras           string `yaml:"extras" toml:"extras" env:"EXTRAS_PATH" required:"true"`
+		Filesystem       string `yaml:"filesystem" toml:"filesystem" env:"FILESYSTEM_WASM_PATH" required:"true"`
+		DynamicLibraries string `yaml:"dynamic_libraries" toml:"dynamic_libraries" env:"DYNAMIC_LIBRARIES" required:"true"`
+		FilesMap         string `yaml:"files_map" toml:"files_map" env:"FILES_MAP" required:"true"`
+	} `yaml:"libraries" toml:"libraries"`
+	HTTP struct {
+		Address   string           `yaml:"address" toml:"address" env:"HTTP_ADDRESS" default:":27016"`
+		Listeners []ListenerConfig `yaml:"listeners" toml:"listeners"`
+		H2C       bool             `yaml:"h2c" toml:"h2c" env:"HTTP_H2C"`
+		AltSvc    string           `yaml:"alt_svc" toml:"alt_svc" env:"HTTP_ALT_SVC"`
+		// SocketMode is the file mode of http.address when it is a "unix:<path>" socket
+		SocketMode string `yaml:"socket_mode" toml:"socket_mode" env:"HTTP_SOCKET_MODE"`
+
+		ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" default:"10s"`
+		ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT" default:"30s"`
+		WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" default:"30s"`
+		IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" default:"120s"`
+		MaxHeaderBytes    int           `yaml:"max_header_bytes" toml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" default:"65536"`
+		MaxBodyBytes      int64         `yaml:"max_body_bytes" toml:"max_body_bytes" env:"HTTP_MAX_BODY_BYTES" default:"65536"`
+	} `yaml:"http" toml:"http"`
+	TLS struct {
+		CertFile string `yaml:"cert_file" toml:"cert_file" env:"TLS_CERT_FILE"`
+		KeyFile  string `yaml:"key_file" toml:"key_file" env:"TLS_KEY_FILE"`
+		Autocert struct {
+			Domains     string `yaml:"domains" toml:"domains" env:"TLS_AUTOCERT_DOMAINS"`
+			Email       string `yaml:"email" toml:"email" env:"TLS_AUTOCERT_EMAIL"`
+			CacheDir    string `yaml:"cache_dir" toml:"cache_dir" env:"TLS_AUTOCERT_CACHE_DIR" default:"certs"`
+			HTTPAddress string `yaml:"http_address" toml:"http_address" env:"TLS_AUTOCERT_HTTP_ADDRESS"`
+		} `yaml:"autocert" toml:"autocert"`
+	} `yaml:"tls" toml:"tls"`
+	Network struct {
+		IP   string `yaml:"ip" toml:"ip" env:"IP"`
+		Port int    `yaml:"port" toml:"port" env:"PORT"`
+	} `yaml:"network" toml:"network"`
+	// ICE lists the STUN/TURN servers handed to web clients by /v1/ice
+	ICE struct {
+		URLs       string        `yaml:"urls" toml:"urls" env:"ICE_URLS"`
+		TURNSecret string        `yaml:"turn_secret" toml:"turn_secret" env:"TURN_SECRET"`
+		TURNTTL    time.Duration `yaml:"turn_ttl" toml:"turn_ttl" env:"TURN_TTL" default:"12h"`
+	} `yaml:"ice" toml:"ice"`
+	Signaling struct {
+		RenegotiationDelay time.Duration `yaml:"renegotiation_delay" toml:"renegotiation_delay" env:"SIGNALING_RENEGOTIATION_DELAY" default:"250ms"`
+		ChallengeBits      int           `yaml:"challenge_bits" toml:"challenge_bits" env:"SIGNALING_CHALLENGE_BITS"`
+		SessionSecret      string        `yaml:"session_secret" toml:"session_secret" env:"SIGNALING_SESSION_SECRET"`
+		SessionTTL         time.Duration `yaml:"session_ttl" toml:"session_ttl" env:"SIGNALING_SESSION_TTL" default:"720h"`
+	} `yaml:"signaling" toml:"signaling"`
+	Firewall struct {
+		MaxPacketSize int `yaml:"max_packet_size" toml:"max_packet_size" env:"FIREWALL_MAX_PACKET_SIZE" default:"4096"`
+		PacketRate    int `yaml:"packet_rate" toml:"packet_rate" env:"FIREWALL_PACKET_RATE" default:"500"`
+		PacketBurst   int `yaml:"packet_burst" toml:"packet_burst" env:"FIREWALL_PACKET_BURST" default:"1000"`
+	} `yaml:"firewall" toml:"firewall"`
+	Server struct {
+		AdminToken        string        `yaml:"admin_token" toml:"admin_token" env:"ADMIN_TOKEN"`
+		AdminTokenHash    string        `yaml:"admin_token_hash" toml:"admin_token_hash" env:"ADMIN_TOKEN_HASH"`
+		DisableXPoweredBy bool          `yaml:"disable_x_powered_by" toml:"disable_x_powered_by" env:"DISABLE_X_POWERED_BY"`
+		XPoweredByValue   string        `yaml:"x_powered_by_value" toml:"x_powered_by_value" env:"X_POWERED_BY_VALUE" default:"yohimik"`
+		ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"10s"`
+		RestartTime       string        `yaml:"restart_time" toml:"restart_time" env:"RESTART_TIME"`
+		// User is the user (name or uid, optionally ":group") to switch to once the ports are bound, before the engine starts
+		User string `yaml:"user" toml:"user" env:"RUN_AS_USER"`
+	} `yaml:"server" toml:"server"`
+	Security struct {
+		ContentSecurityPolicy string `yaml:"content_security_policy" toml:"content_security_policy" env:"CONTENT_SECURITY_POLICY"`
+		FrameAncestors        string `yaml:"frame_ancestors" toml:"frame_ancestors" env:"FRAME_ANCESTORS"`
+		ReferrerPolicy        string `yaml:"referrer_policy" toml:"referrer_policy" env:"REFERRER_POLICY" default:"strict-origin-when-cross-origin"`
+		CrossOriginIsolation  bool   `yaml:"cross_origin_isolation" toml:"cross_origin_isolation" env:"CROSS_ORIGIN_ISOLATION"`
+	} `yaml:"security" toml:"security"`
+	CORS struct {
+		AllowedOrigins   string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+		AllowedMethods   string `yaml:"allowed_methods" toml:"allowed_methods" env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,DELETE"`
+		AllowCredentials bool   `yaml:"allow_credentials" toml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	} `yaml:"cors" toml:"cors"`
+	Assets struct {
+		CacheControl string `yaml:"cache_control" toml:"cache_control" env:"ASSETS_CACHE_CONTROL" default:"no-cache"`
+		Immutable    string `yaml:"immutable" toml:"immutable" env:"ASSETS_IMMUTABLE" default:"assets/*"`
+	} `yaml:"assets" toml:"assets"`
+	Content struct {
+		ArchiveDir string `yaml:"archive_dir" toml:"archive_dir" env:"CONTENT_ARCHIVE_DIR"`
+		Archives   string `yaml:"archives" toml:"archives" env:"CONTENT_ARCHIVES"`
+		FastDL     struct {
+			Dir       string `yaml:"dir" toml:"dir" env:"FASTDL_DIR"`
+			Rate      int64  `yaml:"rate" toml:"rate" env:"FASTDL_RATE"`
+			TotalRate int64  `yaml:"total_rate" toml:"total_rate" env:"FASTDL_TOTAL_RATE"`
+		} `yaml:"fastdl" toml:"fastdl"`
+	} `yaml:"content" toml:"content"`
+	AccessLog struct {
+		Enabled    bool    `yaml:"enabled" toml:"enabled" env:"ACCESS_LOG" default:"true"`
+		Exclude    string  `yaml:"exclude" toml:"exclude" env:"ACCESS_LOG_EXCLUDE" default:"/healthz,/readyz,/livez,/metrics"`
+		Sampled    string  `yaml:"sampled" toml:"sampled" env:"ACCESS_LOG_SAMPLED" default:"/assets/,/cstrike/,/content/,/fastdl/"`
+		SampleRate float64 `yaml:"sample_rate" toml:"sample_rate" env:"ACCESS_LOG_SAMPLE_RATE" default:"0.1"`
+	} `yaml:"access_log" toml:"access_log"`
+	Log struct {
+		File           string        `yaml:"file" toml:"file" env:"LOG_FILE"`
+		MaxSizeMB      int           `yaml:"max_size_mb" toml:"max_size_mb" env:"LOG_MAX_SIZE_MB" default:"100"`
+		RotateInterval time.Duration `yaml:"rotate_interval" toml:"rotate_interval" env:"LOG_ROTATE_INTERVAL"`
+		MaxBackups     int           `yaml:"max_backups" toml:"max_backups" env:"LOG_MAX_BACKUPS" default:"10"`
+		MaxAge         time.Duration `yaml:"max_age" toml:"max_age" env:"LOG_MAX_AGE" default:"168h"`
+		// Dedupe collapses repeated identical lines into "last message repeated N times"
+		Dedupe bool `yaml:"dedupe" toml:"dedupe" env:"LOG_DEDUPE"`
+		// ScrubIPs reduces IP addresses in the log file to their network
+		ScrubIPs bool `yaml:"scrub_ips" toml:"scrub_ips" env:"LOG_SCRUB_IPS"`
+	} `yaml:"log" toml:"log"`
+	Metrics struct {
+		Username string `yaml:"username" toml:"username" env:"METRICS_USERNAME"`
+		Password string `yaml:"password" toml:"password" env:"METRICS_PASSWORD"`
+	} `yaml:"metrics" toml:"metrics"`
+	Debug struct {
+		MutexProfileFraction int `yaml:"mutex_profile_fraction" toml:"mutex_profile_fraction" env:"DEBUG_MUTEX_PROFILE_FRACTION"`
+		BlockProfileRate     int `yaml:"block_profile_rate" toml:"block_profile_rate" env:"DEBUG_BLOCK_PROFILE_RATE"`
+	} `yaml:"debug" toml:"debug"`
+	// Features switch whole subsystems off for minimal deployments, they are resolved at startup
+	Features struct {
+		Voice   bool `yaml:"voice" toml:"voice" env:"FEATURE_VOICE" default:"true"`
+		Admin   bool `yaml:"admin" toml:"admin" env:"FEATURE_ADMIN" default:"true"`
+		Metrics bool `yaml:"metrics" toml:"metrics" env:"FEATURE_METRICS" default:"true"`
+	} `yaml:"features" toml:"features"`
+	Watchdog struct {
+		Timeout        time.Duration `yaml:"timeout" toml:"timeout" env:"ENGINE_WATCHDOG_TIMEOUT" default:"30s"`
+		CrashReportDir string        `yaml:"crash_report_dir" toml:"crash_report_dir" env:"CRASH_REPORT_DIR" default:"crashes"`
+	} `yaml:"watchdog" toml:"watchdog"`
+}
+
+// EngineConfig holds the configuration for the Xash3D engine (JSON response)
+type EngineConfig struct {
+	Arguments        []string          `json:"arguments"`
+	Console          []string          `json:"console"`
+	GameDir          string            `json:"game_dir"`
+	Libraries        map[string]string `json:"libraries"`
+	DynamicLibraries []string          `json:"dynamic_libraries"`
+	FilesMap         map[string]string `json:"files_map"`
+}
+
+var (
+	currentConfig    atomic.Pointer[Config]
+	engineConfigJSON atomic.Pointer[[]byte]
+)
+
+// appConfig returns the active configuration.
+// It is replaced as a whole on reload, so callers must not modify it.
+func appConfig() *Config {
+	return currentConfig.Load()
+}
+
+// configHandler returns the pre-serialized engine configuration
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(*engineConfigJSON.Load())
+}
+
+// sliceArgs converts a comma-separated string into a slice of strings
+func sliceArgs(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
+// parseFilesMap converts "from:to,from:to" format into map[string]string
+func parseFilesMap(value string) map[string]string {
+	result := make(map[string]string)
+	if value == "" {
+		return result
+	}
+	pairs := strings.Split(value, ",")
+	for _, pair := range pairs {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) == 2 {
+			result[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return result
+}
+
+// configFiles returns the config file to load: CONFIG_FILE if set, otherwise server.yaml if present
+func configFiles() ([]string, error) {
+	if file, ok := os.LookupEnv("CONFIG_FILE"); ok && file != "" {
+		if _, err := os.Stat(file); err != nil {
+			return nil, err
+		}
+		return []string{file}, nil
+	}
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		return []string{defaultConfigFile}, nil
+	}
+	return nil, nil
+}
+
+// readConfig reads the configuration from the config file and environment.
+// On error the returned config holds whatever was loaded before the failure.
+func readConfig() (*Config, error) {
+	cfg := &Config{}
+	files, err := configFiles()
+	if err != nil {
+		return cfg, fmt.Errorf("failed to find config file: %w", err)
+	}
+	if err := configor.New(&configor.Config{Silent: true}).Load(cfg, files...); err != nil {
+		return cfg, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := loadSecretFiles(cfg); err != nil {
+		return cfg, err
+	}
+	if err := validateListeners(listenerConfigs(cfg)); err != nil {
+		return cfg, err
+	}
+	if err := validateTLS(cfg); err != nil {
+		return cfg, err
+	}
+	if value := cfg.Server.AdminTokenHash; value != "" {
+		if _, _, err := parseTokenHash(value); err != nil {
+			return cfg, err
+		}
+	}
+	if err := validateTickRate(cfg.Engine.TickRate); err != nil {
+		return cfg, err
+	}
+	if value := cfg.Server.RestartTime; value != "" {
+		if _, err := time.Parse("15:04", value); err != nil {
+			return cfg, fmt.Errorf("invalid restart time %q: %w", value, err)
+		}
+	}
+	return cfg, nil
+}
+
+// applyConfig makes cfg the active configuration and serializes the engine config JSON once
+func applyConfig(cfg *Config) error {
+	engineConfig := EngineConfig{
+		Arguments: sliceArgs(cfg.Engine.Arguments),
+		Console:   sliceArgs(cfg.Engine.Console),
+		GameDir:   cfg.Engine.GameDir,
+		Libraries: map[string]string{
+			"client":     cfg.Libraries.Client,
+			"server":     cfg.Libraries.Server,
+			"extras":     cfg.Libraries.Extras,
+			"menu":       cfg.Libraries.Menu,
+			"filesystem": cfg.Libraries.Filesystem,
+		},
+		DynamicLibraries: sliceArgs(cfg.Libraries.DynamicLibraries),
+		FilesMap:         parseFilesMap(cfg.Libraries.FilesMap),
+	}
+
+	data, err := json.Marshal(engineConfig)
+	if err != nil {
+		return fmt.Errorf("failed to serialize config: %w", err)
+	}
+	engineConfigJSON.Store(&data)
+	currentConfig.Store(cfg)
+	applyProfilingRates(cfg)
+	return nil
+}
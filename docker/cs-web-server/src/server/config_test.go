@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// useTestConfig activates a configuration with the defaults the tests rely on and restores the previous one afterwards
+func useTestConfig(t testing.TB) *Config {
+	previous := currentConfig.Load()
+	cfg := &Config{}
+	cfg.HTTP.MaxBodyBytes = 65536
+	cfg.Signaling.SessionSecret = "test-secret"
+	cfg.Signaling.SessionTTL = time.Hour
+	cfg.Firewall.MaxPacketSize = 4096
+	cfg.Firewall.PacketRate = 500
+	cfg.Firewall.PacketBurst = 1000
+	currentConfig.Store(cfg)
+	t.Cleanup(func() {
+		currentConfig.Store(previous)
+	})
+	return cfg
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strconv"
+	"time"
+)
+
+const (
+	// debugPprofPrefix is where net/http/pprof expects its handlers, its index links are relative to it
+	debugPprofPrefix = "/debug/pprof/"
+
+	defaultProfileSeconds = 30
+	maxProfileSeconds     = 300
+)
+
+// profileTypes are the profiles POST /v1/debug/profile records, heap records the allocations during the period
+var profileTypes = map[string]http.Handler{
+	"cpu":  http.HandlerFunc(pprof.Profile),
+	"heap": pprof.Handler("heap"),
+}
+
+type profileRequest struct {
+	Type    string `json:"type"`
+	Seconds int    `json:"seconds"`
+}
+
+// registerDebugRoutes adds the pprof handlers, the goroutine dump and packet captures to the admin route group
+func registerDebugRoutes(rt *router) {
+	rt.handle(routeAdmin, debugPprofPrefix, pprof.Index)
+	rt.handle(routeAdmin, debugPprofPrefix+"cmdline", pprof.Cmdline)
+	rt.handle(routeAdmin, debugPprofPrefix+"profile", pprof.Profile)
+	rt.handle(routeAdmin, debugPprofPrefix+"symbol", pprof.Symbol)
+	rt.handle(routeAdmin, debugPprofPrefix+"trace", pprof.Trace)
+	rt.handle(routeAdmin, "/v1/debug/goroutines", goroutinesHandler)
+	rt.handle(routeAdmin, "/v1/debug/profile", profileHandler)
+	rt.handle(routeAdmin, "/v1/debug/capture", captureHandler)
+}
+
+// applyProfilingRates enables the mutex and block profiles, both are empty while their rate is 0
+func applyProfilingRates(cfg *Config) {
+	runtime.SetMutexProfileFraction(cfg.Debug.MutexProfileFraction)
+	runtime.SetBlockProfileRate(cfg.Debug.BlockProfileRate)
+}
+
+// goroutinesHandler dumps the stacks of all goroutines as text, grouped by identical stacks with ?grouped=true
+func goroutinesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	debug := 2
+	if grouped, _ := strconv.ParseBool(r.URL.Query().Get("grouped")); grouped {
+		debug = 1
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := runtimepprof.Lookup("goroutine").WriteTo(w, debug); err != nil {
+		log.Errorf("Failed to dump goroutines: %v", err)
+	}
+}
+
+// profileHandler records a CPU or heap profile for the requested seconds and returns it as a pprof file:
+// POST /v1/debug/profile {"type": "cpu", "seconds": 30}
+func profileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := profileRequest{Type: "cpu", Seconds: defaultProfileSeconds}
+	if err := decodeJSON(w, r, &req); err != nil && err != io.EOF {
+		writeBodyError(w, err)
+		return
+	}
+	handler, ok := profileTypes[req.Type]
+	if !ok {
+		http.Error(w, "type must be cpu or heap", http.StatusBadRequest)
+		return
+	}
+	if req.Seconds < 1 || req.Seconds > maxProfileSeconds {
+		http.Error(w, fmt.Sprintf("seconds must be between 1 and %d", maxProfileSeconds), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Recording %s profile for %ds", req.Type, req.Seconds)
+	// the pprof handlers read the duration from the query
+	profile := r.Clone(r.Context())
+	profile.URL.RawQuery = fmt.Sprintf("seconds=%d", req.Seconds)
+	handler.ServeHTTP(&profileWriter{
+		ResponseWriter: w,
+		filename:       fmt.Sprintf("%s-%s.pprof", req.Type, time.Now().Format(logFileTimeFormat)),
+	}, profile)
+}
+
+// profileWriter names the downloaded profile, replacing the generic name the pprof handlers set
+type profileWriter struct {
+	http.ResponseWriter
+	filename    string
+	wroteHeader bool
+}
+
+func (p *profileWriter) WriteHeader(status int) {
+	if !p.wroteHeader && status == http.StatusOK {
+		p.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, p.filename))
+	}
+	p.wroteHeader = true
+	p.ResponseWriter.WriteHeader(status)
+}
+
+func (p *profileWriter) Write(b []byte) (int, error) {
+	if !p.wroteHeader {
+		p.WriteHeader(http.StatusOK)
+	}
+	return p.ResponseWriter.Write(b)
+}
@@ -0,0 +1,35 @@
+//go:build !mockengine
+
+package main
+
+import (
+	"github.com/yohimik/goxash3d-fwgs/pkg"
+)
+
+// The engine bindings, engine_mock.go replaces them in builds with the mockengine tag
+type (
+	engineNet    = goxash3d_fwgs.BaseNet
+	enginePacket = goxash3d_fwgs.Packet
+	engineAddr   = goxash3d_fwgs.Addr
+	indexPool    = goxash3d_fwgs.BytesPool
+)
+
+var newIndexPool = goxash3d_fwgs.NewBytesPool
+
+func newEngineNet() *engineNet {
+	return goxash3d_fwgs.NewBaseNet(goxash3d_fwgs.BaseNetOptions{
+		HostName: "webxash",
+		HostID:   3000,
+	})
+}
+
+// engineVersion returns the version of the engine bindings, which pins the xash3d-fwgs build
+func engineVersion() string {
+	return moduleVersion(engineModule)
+}
+
+// runEngine runs the engine on n until it exits and returns its exit code
+func runEngine(n *SFUNet) int {
+	goxash3d_fwgs.DefaultXash3D.Net = n
+	return goxash3d_fwgs.DefaultXash3D.SysStart()
+}
@@ -0,0 +1,95 @@
+//go:build mockengine
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// The mock engine replaces the bindings in builds with the mockengine tag, so the server builds without CGO and
+// libxash. It runs frames like the engine and echoes every packet back to its sender.
+type (
+	enginePacket struct {
+		Data []byte
+		Addr engineAddr
+	}
+	engineAddr struct {
+		IP   [4]byte
+		Port uint16
+	}
+)
+
+type engineNet struct {
+	packets chan enginePacket
+}
+
+func newEngineNet() *engineNet {
+	return &engineNet{packets: make(chan enginePacket, 128)}
+}
+
+// PushPacket queues a packet for the engine, dropping it when the queue is full like a full socket buffer
+func (n *engineNet) PushPacket(packet enginePacket) {
+	select {
+	case n.packets <- packet:
+	default:
+	}
+}
+
+func (n *engineNet) RecvFrom() *enginePacket {
+	select {
+	case packet := <-n.packets:
+		return &packet
+	default:
+		return nil
+	}
+}
+
+// indexPool hands out the byte indexes of the peer slots
+type indexPool struct {
+	free chan uint8
+}
+
+func newIndexPool(n int) *indexPool {
+	p := &indexPool{free: make(chan uint8, n)}
+	for i := 0; i < n; i++ {
+		p.free <- uint8(i)
+	}
+	return p
+}
+
+func (p *indexPool) TryGet() (uint8, error) {
+	select {
+	case v := <-p.free:
+		return v, nil
+	default:
+		return 0, errors.New("pool is empty")
+	}
+}
+
+func (p *indexPool) TryPut(v uint8) error {
+	select {
+	case p.free <- v:
+		return nil
+	default:
+		return errors.New("pool is full")
+	}
+}
+
+// engineVersion reports the mock, no xash3d-fwgs build is linked
+func engineVersion() string {
+	return "mock"
+}
+
+// runEngine runs mock frames at the tick rate forever: each frame reads all queued packets and echoes them
+func runEngine(n *SFUNet) int {
+	fmt.Fprintln(os.Stderr, "Running the mock engine, packets are echoed back")
+	for range time.NewTicker(time.Second / time.Duration(tickRate(appConfig()))).C {
+		for packet := n.RecvFrom(); packet != nil; packet = n.RecvFrom() {
+			n.SendTo(0, *packet, 0)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fastDLPrefix = "/fastdl/"
+
+// fastDLTypes are the downloadable content types, everything else (configs, plugins, logs) is never served
+var fastDLTypes = map[string]string{
+	".bsp": "application/octet-stream",
+	".res": "text/plain; charset=utf-8",
+	".txt": "text/plain; charset=utf-8",
+	".wad": "application/octet-stream",
+	".mdl": "application/octet-stream",
+	".spr": "application/octet-stream",
+	".wav": "audio/wav",
+	".mp3": "audio/mpeg",
+	".tga": "image/x-tga",
+	".bmp": "image/bmp",
+}
+
+// fastDLTotalLimiter limits the bandwidth shared by all downloads
+var fastDLTotalLimiter = &bandwidthLimiter{}
+
+// fastDLHandler serves the game directory for sv_downloadurl downloads of native clients
+func fastDLHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := appConfig().Content.FastDL
+	if cfg.Dir == "" {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), strings.TrimSuffix(fastDLPrefix, "/"))
+	contentType, ok := fastDLTypes[strings.ToLower(path.Ext(name))]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(cfg.Dir, filepath.FromSlash(name)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	fastDLTotalLimiter.setRate(cfg.TotalRate)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", appConfig().Assets.CacheControl)
+	http.ServeContent(&throttledResponseWriter{
+		ResponseWriter: w,
+		limiters:       []*bandwidthLimiter{{rate: cfg.Rate}, fastDLTotalLimiter},
+	}, r, name, info.ModTime(), f)
+}
+
+// bandwidthLimiter spaces writes so they don't exceed rate bytes per second, 0 means unlimited
+type bandwidthLimiter struct {
+	lock sync.Mutex
+	rate int64
+	next time.Time
+}
+
+func (b *bandwidthLimiter) setRate(rate int64) {
+	b.lock.Lock()
+	b.rate = rate
+	b.lock.Unlock()
+}
+
+// reserve accounts n bytes and returns how long to wait before sending them
+func (b *bandwidthLimiter) reserve(n int) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	wait := b.next.Sub(now)
+	b.next = b.next.Add(time.Duration(int64(n) * int64(time.Second) / b.rate))
+	return wait
+}
+
+// throttledResponseWriter writes the response in chunks within the bandwidth of all its limiters
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	limiters []*bandwidthLimiter
+}
+
+// throttleChunkSize keeps the delay between chunks short enough for a smooth transfer
+const throttleChunkSize = 16 * 1024
+
+func (t *throttledResponseWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), throttleChunkSize)]
+		var wait time.Duration
+		for _, limiter := range t.limiters {
+			wait = max(wait, limiter.reserve(len(chunk)))
+		}
+		time.Sleep(wait)
+
+		n, err := t.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// ReadFrom hides the underlying io.ReaderFrom so sendfile can't bypass the throttling
+func (t *throttledResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.CopyBuffer(struct{ io.Writer }{t}, r, make([]byte, throttleChunkSize))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"sync/atomic"
+	"time"
+)
+
+// connectionlessHeader starts out-of-band engine packets (challenge, connect, server queries)
+var connectionlessHeader = []byte{0xff, 0xff, 0xff, 0xff}
+
+// dropReason is why the firewall dropped a packet, the names are the values of the metrics label
+type dropReason int
+
+const (
+	dropSize dropReason = iota
+	dropRate
+	dropMalformed
+	dropReasons
+)
+
+var dropReasonNames = [dropReasons]string{"size", "rate", "malformed"}
+
+// firewallDrops counts the packets dropped by each rule
+var firewallDrops [dropReasons]atomic.Uint64
+
+// packetFilter applies the firewall rules to the packets of one peer before they reach the engine.
+// It's used by the peer's read loop only.
+type packetFilter struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow reports whether the packet may be pushed to the engine, counting it when it's dropped
+func (f *packetFilter) allow(packet []byte, now time.Time) bool {
+	reason, ok := f.check(packet, now)
+	if !ok {
+		firewallDrops[reason].Add(1)
+	}
+	return ok
+}
+
+func (f *packetFilter) check(packet []byte, now time.Time) (dropReason, bool) {
+	cfg := appConfig().Firewall
+	if cfg.MaxPacketSize > 0 && len(packet) > cfg.MaxPacketSize {
+		return dropSize, false
+	}
+	if cfg.PacketRate > 0 {
+		burst := float64(max(cfg.PacketBurst, cfg.PacketRate))
+		if f.last.IsZero() {
+			f.tokens = burst
+		} else {
+			f.tokens = min(burst, f.tokens+now.Sub(f.last).Seconds()*float64(cfg.PacketRate))
+		}
+		f.last = now
+		if f.tokens < 1 {
+			return dropRate, false
+		}
+		f.tokens--
+	}
+	if malformedPacket(packet) {
+		return dropMalformed, false
+	}
+	return 0, true
+}
+
+// malformedPacket matches packets no client sends: shorter than any packet header, or connectionless
+// packets that don't start with a printable command
+func malformedPacket(packet []byte) bool {
+	if len(packet) < len(connectionlessHeader) {
+		return true
+	}
+	if !bytes.HasPrefix(packet, connectionlessHeader) {
+		return false
+	}
+	command := packet[len(connectionlessHeader):]
+	return len(command) == 0 || command[0] <= ' ' || command[0] > '~'
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func FuzzMalformedPacket(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0xff, 0xff, 0xff})
+	f.Add([]byte("\xff\xff\xff\xffgetchallenge steam\n"))
+	f.Add([]byte("\xff\xff\xff\xff\x00"))
+	f.Add([]byte{0x01, 0x00, 0x00, 0x00, 0x02})
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		malformed := malformedPacket(packet)
+		if len(packet) < len(connectionlessHeader) && !malformed {
+			t.Fatalf("packet shorter than a header passed: %x", packet)
+		}
+		if len(packet) >= len(connectionlessHeader) && !bytes.HasPrefix(packet, connectionlessHeader) && malformed {
+			t.Fatalf("sequenced packet dropped: %x", packet)
+		}
+	})
+}
+
+func FuzzPacketFilter(f *testing.F) {
+	f.Add([]byte("\xff\xff\xff\xffconnect 48"), uint16(10), uint8(3))
+	f.Add(bytes.Repeat([]byte{0x01}, 5000), uint16(0), uint8(1))
+	f.Add([]byte{0xff}, uint16(1000), uint8(255))
+	f.Fuzz(func(t *testing.T, packet []byte, interval uint16, count uint8) {
+		cfg := useTestConfig(t)
+		cfg.Firewall.PacketRate = 50
+		cfg.Firewall.PacketBurst = 100
+		filter := packetFilter{}
+		now := time.Unix(0, 0)
+		passed := 0
+		for range count {
+			now = now.Add(time.Duration(interval) * time.Microsecond)
+			reason, ok := filter.check(packet, now)
+			if !ok {
+				if reason != dropSize && reason != dropRate && reason != dropMalformed {
+					t.Fatalf("unknown drop reason %d", reason)
+				}
+				continue
+			}
+			if len(packet) > cfg.Firewall.MaxPacketSize || malformedPacket(packet) {
+				t.Fatalf("packet passed the firewall: %x", packet)
+			}
+			passed++
+		}
+		// the bucket starts full and refills at the packet rate
+		if limit := float64(cfg.Firewall.PacketBurst) + now.Sub(time.Unix(0, 0)).Seconds()*float64(cfg.Firewall.PacketRate); float64(passed) > limit {
+			t.Fatalf("%d packets passed, the limit is %f", passed, limit)
+		}
+		if filter.tokens < 0 || filter.tokens > float64(cfg.Firewall.PacketBurst) {
+			t.Fatalf("tokens out of range: %f", filter.tokens)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultTickRate is the dedicated server's sys_ticrate when engine.tickrate isn't set
+	defaultTickRate = 100
+	minTickRate     = 20
+	maxTickRate     = 1000
+
+	// pollRateInterval is how often the engine's poll rate is measured
+	pollRateInterval = 10 * time.Second
+	// slowPollRate is the share of the tick rate below which the engine is reported as slow
+	slowPollRate = 0.9
+)
+
+var (
+	// enginePollRate holds the float64 bits of the empty network polls per second over the last pollRateInterval
+	enginePollRate atomic.Uint64
+	// enginePollQuantiles are the poll interval quantiles over the last pollRateInterval
+	enginePollQuantiles atomic.Pointer[pollQuantiles]
+)
+
+// pollQuantiles are quantiles of the time between empty engine network polls in seconds
+type pollQuantiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// validateTickRate accepts 0 for the engine default or a rate the engine doesn't clamp
+func validateTickRate(rate int) error {
+	if rate != 0 && (rate < minTickRate || rate > maxTickRate) {
+		return fmt.Errorf("invalid tick rate %d: must be between %d and %d", rate, minTickRate, maxTickRate)
+	}
+	return nil
+}
+
+// tickRate returns the frame rate the engine is configured to run at
+func tickRate(cfg *Config) int {
+	if cfg.Engine.TickRate == 0 {
+		return defaultTickRate
+	}
+	return cfg.Engine.TickRate
+}
+
+// tickRateArgs returns the engine arguments setting the configured tick rate.
+// They are appended to the command line, so they win over a +sys_ticrate passed by hand.
+func tickRateArgs(cfg *Config) []string {
+	if cfg.Engine.TickRate == 0 {
+		return nil
+	}
+	return []string{"+sys_ticrate", strconv.Itoa(cfg.Engine.TickRate)}
+}
+
+// runFrameRateMonitor measures the engine's poll rate and logs when it falls behind the tick rate.
+// The engine polls at least once per frame, so a poll rate below the tick rate means the frames are too.
+func runFrameRateMonitor() {
+	slow := false
+	polls := enginePollTimes.count.Load()
+	buckets := enginePollTimes.counts()
+	for range time.NewTicker(pollRateInterval).C {
+		count := enginePollTimes.count.Load()
+		rate := float64(count-polls) / pollRateInterval.Seconds()
+		polls = count
+		enginePollRate.Store(math.Float64bits(rate))
+
+		// the quantiles only cover this interval, the histogram itself counts since the start
+		current := enginePollTimes.counts()
+		window := make([]uint64, len(current))
+		for i := range current {
+			window[i] = current[i] - buckets[i]
+		}
+		buckets = current
+		enginePollQuantiles.Store(&pollQuantiles{
+			P50: histogramQuantile(0.5, pollIntervalBuckets, window),
+			P95: histogramQuantile(0.95, pollIntervalBuckets, window),
+			P99: histogramQuantile(0.99, pollIntervalBuckets, window),
+		})
+		if lastEngineHeartbeat().IsZero() {
+			continue
+		}
+
+		target := float64(tickRate(appConfig()))
+		if rate < target*slowPollRate && !slow {
+			log.Warnf("Engine runs at most %.1f fps, below its tick rate of %.0f", rate, target)
+		} else if rate >= target*slowPollRate && slow {
+			log.Infof("Engine polls %.1f times per second again", rate)
+		}
+		slow = rate < target*slowPollRate
+	}
+}
+
+// lastPollQuantiles returns the poll interval quantiles measured last, zero before the first measurement
+func lastPollQuantiles() pollQuantiles {
+	if quantiles := enginePollQuantiles.Load(); quantiles != nil {
+		return *quantiles
+	}
+	return pollQuantiles{}
+}
+
+// achievedPollRate returns the engine's empty network polls per second measured last
+func achievedPollRate() float64 {
+	return math.Float64frombits(enginePollRate.Load())
+}
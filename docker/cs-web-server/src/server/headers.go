@@ -0,0 +1,59 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"strings"
+)
+
+// Default policies, kept out of the config struct tags as configor parses default values as YAML.
+// The engine's side modules are loaded with emscripten dynamic linking, which evaluates their EM_JS code.
+const (
+	defaultContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-eval' 'wasm-unsafe-eval'; " +
+		"style-src 'self' 'unsafe-inline'; img-src 'self' data: blob:; media-src 'self' blob:; worker-src 'self' blob:; connect-src 'self'"
+	defaultFrameAncestors = "'self'"
+)
+
+// securityHeaders applies the configured security and server headers to every response
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := appConfig()
+		header := w.Header()
+
+		header.Set("X-Content-Type-Options", "nosniff")
+		if policy := contentSecurityPolicy(cfg); policy != "" {
+			header.Set("Content-Security-Policy", policy)
+		}
+		if cfg.Security.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", cfg.Security.ReferrerPolicy)
+		}
+		if cfg.Security.CrossOriginIsolation {
+			// makes SharedArrayBuffer available to a threaded wasm client
+			header.Set("Cross-Origin-Opener-Policy", "same-origin")
+			header.Set("Cross-Origin-Embedder-Policy", "require-corp")
+		}
+		if !cfg.Server.DisableXPoweredBy {
+			header.Set("X-Powered-By", cfg.Server.XPoweredByValue)
+		}
+		if cfg.HTTP.AltSvc != "" {
+			header.Set("Alt-Svc", cfg.HTTP.AltSvc)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// contentSecurityPolicy returns the Content-Security-Policy with the configured frame-ancestors, empty if disabled
+func contentSecurityPolicy(cfg *Config) string {
+	policy := cmp.Or(strings.TrimSpace(cfg.Security.ContentSecurityPolicy), defaultContentSecurityPolicy)
+	frameAncestors := cmp.Or(strings.TrimSpace(cfg.Security.FrameAncestors), defaultFrameAncestors)
+
+	var directives []string
+	if policy != "off" {
+		directives = append(directives, strings.TrimSuffix(policy, ";"))
+	}
+	if frameAncestors != "off" {
+		directives = append(directives, "frame-ancestors "+frameAncestors)
+	}
+	return strings.Join(directives, "; ")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const defaultLivenessTimeout = 30 * time.Second
+
+// sfuReady is set once the WebRTC API is configured and the HTTP server is starting
+var sfuReady atomic.Bool
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// healthzHandler reports that the process is up
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// readyzHandler reports whether the server can accept new players
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{}
+	ready := true
+	check := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	if lastEngineHeartbeat().IsZero() {
+		check("engine", fmt.Errorf("not initialized"))
+	} else {
+		check("engine", nil)
+	}
+	if !sfuReady.Load() {
+		check("sfu", fmt.Errorf("not listening"))
+	} else {
+		check("sfu", nil)
+	}
+	if _, ok := maintenance.check(); ok {
+		check("maintenance", fmt.Errorf("enabled"))
+	} else {
+		check("maintenance", nil)
+	}
+	check("assets", checkAssets())
+
+	if !ready {
+		writeJSON(w, http.StatusServiceUnavailable, healthResponse{Status: "unavailable", Checks: checks})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok", Checks: checks})
+}
+
+// livezHandler reports whether the engine is still running frames
+func livezHandler(w http.ResponseWriter, r *http.Request) {
+	last := lastEngineHeartbeat()
+	if last.IsZero() {
+		writeJSON(w, http.StatusOK, healthResponse{Status: "starting"})
+		return
+	}
+
+	timeout := appConfig().Watchdog.Timeout
+	if timeout <= 0 {
+		timeout = defaultLivenessTimeout
+	}
+	since := time.Since(last)
+	if since > timeout {
+		writeJSON(w, http.StatusServiceUnavailable, healthResponse{
+			Status: "unavailable",
+			Checks: map[string]string{"engine": fmt.Sprintf("no frame for %v", since.Round(time.Second))},
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// checkAssets verifies that the client page and the configured libraries are served
+func checkAssets() error {
+	paths := []string{
+		"index.html",
+		"valve.zip",
+		appConfig().Libraries.Client,
+		appConfig().Libraries.Server,
+		appConfig().Libraries.Menu,
+		appConfig().Libraries.Extras,
+		appConfig().Libraries.Filesystem,
+	}
+	for _, p := range paths {
+		if _, err := os.Stat(filepath.Join("public", p)); err != nil {
+			return fmt.Errorf("missing %s", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// iceServer is an RTCIceServer as the browser's RTCPeerConnection expects it
+type iceServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+type iceResponse struct {
+	ICEServers []iceServer `json:"ice_servers"`
+	// TTL is how many seconds the TURN credentials stay valid
+	TTL int `json:"ttl,omitempty"`
+}
+
+// turnCredentials mints credentials for the TURN REST API that coturn implements with use-auth-secret:
+// the username is "<expiry unix>:<id>" and the credential is base64(HMAC-SHA1(secret, username)).
+// Each call uses a new id, so the relay can tell sessions apart.
+func turnCredentials(secret string, ttl time.Duration) (string, string) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	username := fmt.Sprintf("%d:%s", time.Now().Add(ttl).Unix(), hex.EncodeToString(id))
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// iceServers splits ice.urls into STUN servers and TURN servers, the TURN servers get fresh credentials.
+// Without ice.turn_secret TURN servers can't be used and are left out.
+func iceServers(cfg *Config) iceResponse {
+	response := iceResponse{ICEServers: []iceServer{}}
+	var stun, turn []string
+	for _, url := range sliceArgs(cfg.ICE.URLs) {
+		if strings.HasPrefix(url, "turn:") || strings.HasPrefix(url, "turns:") {
+			turn = append(turn, url)
+		} else {
+			stun = append(stun, url)
+		}
+	}
+	if len(stun) > 0 {
+		response.ICEServers = append(response.ICEServers, iceServer{URLs: stun})
+	}
+	if len(turn) > 0 && cfg.ICE.TURNSecret != "" {
+		username, credential := turnCredentials(cfg.ICE.TURNSecret, cfg.ICE.TURNTTL)
+		response.ICEServers = append(response.ICEServers, iceServer{URLs: turn, Username: username, Credential: credential})
+		response.TTL = int(cfg.ICE.TURNTTL.Seconds())
+	}
+	return response
+}
+
+// iceHandler returns the STUN/TURN servers for a new peer connection of the web client.
+// It's public like /config, the credentials are short-lived so no long-lived secret ships with the client.
+func iceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, iceServers(appConfig()))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	gonet "net"
+	"net/http"
+	"time"
+)
+
+// writeDeadlineChunkSize is how much of a file is sent before the write deadline is extended again
+const writeDeadlineChunkSize = 1 << 20
+
+// decodeJSON decodes the request body into v, reading at most the configured body size
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, appConfig().HTTP.MaxBodyBytes)).Decode(v)
+}
+
+// writeBodyError answers a request whose body couldn't be decoded
+func writeBodyError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "invalid request body", http.StatusBadRequest)
+}
+
+// writeTimeouts gives every write to the client the configured time to complete, so stalled clients
+// are disconnected while downloads of any size that keep progressing are not cut off.
+func writeTimeouts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := appConfig().HTTP.WriteTimeout
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&writeDeadlineWriter{
+			ResponseWriter: w,
+			controller:     http.NewResponseController(w),
+			timeout:        timeout,
+		}, r)
+	})
+}
+
+// writeDeadlineWriter extends the connection's write deadline before each write
+type writeDeadlineWriter struct {
+	http.ResponseWriter
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+func (d *writeDeadlineWriter) extend() {
+	_ = d.controller.SetWriteDeadline(time.Now().Add(d.timeout))
+}
+
+func (d *writeDeadlineWriter) WriteHeader(status int) {
+	d.extend()
+	d.ResponseWriter.WriteHeader(status)
+}
+
+func (d *writeDeadlineWriter) Write(p []byte) (int, error) {
+	d.extend()
+	return d.ResponseWriter.Write(p)
+}
+
+// ReadFrom sends files in chunks, keeping sendfile while extending the deadline in between
+func (d *writeDeadlineWriter) ReadFrom(r io.Reader) (int64, error) {
+	var written int64
+	for {
+		d.extend()
+		n, err := io.CopyN(d.ResponseWriter, r, writeDeadlineChunkSize)
+		written += n
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// Hijack hands the connection over to the WebSocket upgrader, which manages its own deadlines
+func (d *writeDeadlineWriter) Hijack() (gonet.Conn, *bufio.ReadWriter, error) {
+	return d.controller.Hijack()
+}
+
+func (d *writeDeadlineWriter) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func FuzzDecodeJSON(f *testing.F) {
+	f.Add(`{"duration":"30s","peer":"10.0.0.1:1000"}`)
+	f.Add(`{"message":"back soon","kick":true}`)
+	f.Add(`{"level":"debug","duration":"5m"}`)
+	f.Add(`{"challenge":"00ff","solution":"12"}`)
+	f.Add(`{`)
+	f.Add(`"` + strings.Repeat("a", 70000) + `"`)
+	f.Fuzz(func(t *testing.T, body string) {
+		cfg := useTestConfig(t)
+		targets := []any{
+			&captureRequest{},
+			&maintenanceRequest{},
+			&restartRequest{},
+			&profileRequest{},
+			&logLevelRequest{},
+			&challengeResponse{},
+		}
+		for _, v := range targets {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			err := decodeJSON(w, r, v)
+			if err == nil {
+				continue
+			}
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) && int64(len(body)) <= cfg.HTTP.MaxBodyBytes {
+				t.Fatalf("body of %d bytes rejected as too large", len(body))
+			}
+			writeBodyError(w, err)
+			if w.Code != http.StatusBadRequest && w.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("body error answered with %d", w.Code)
+			}
+		}
+	})
+}
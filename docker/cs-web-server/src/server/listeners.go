@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	gonet "net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Route groups a listener can serve
+const (
+	routePublic  = "public"  // client page, assets, /config, /v1/ice and signaling
+	routeHealth  = "health"  // /healthz, /readyz, /livez
+	routeAdmin   = "admin"   // /v1 admin API
+	routeMetrics = "metrics" // /metrics
+)
+
+var routeGroups = []string{routePublic, routeHealth, routeAdmin, routeMetrics}
+
+const (
+	// unixAddressPrefix marks a listener address as a unix socket path: "unix:/run/xash/http.sock"
+	unixAddressPrefix = "unix:"
+	defaultSocketMode = 0o660
+)
+
+// ListenerConfig is an HTTP listener serving a subset of the route groups
+type ListenerConfig struct {
+	Address string   `yaml:"address" toml:"address"`
+	Routes  []string `yaml:"routes" toml:"routes"`
+	TLS     bool     `yaml:"tls" toml:"tls"`
+	// Mode is the octal file mode of a unix socket, 0660 if empty
+	Mode string `yaml:"mode" toml:"mode"`
+}
+
+var (
+	httpServersLock sync.Mutex
+	httpServers     []*http.Server
+
+	// httpBound is closed once every listener has bound its port, privileges can be dropped then
+	httpBound     = make(chan struct{})
+	markHTTPBound = sync.OnceFunc(func() { close(httpBound) })
+)
+
+// listenerConfigs returns the configured listeners, or a single listener on http.address serving everything
+func listenerConfigs(cfg *Config) []ListenerConfig {
+	if len(cfg.HTTP.Listeners) > 0 {
+		return cfg.HTTP.Listeners
+	}
+	return []ListenerConfig{{Address: cfg.HTTP.Address, Routes: routeGroups, TLS: tlsEnabled(cfg), Mode: cfg.HTTP.SocketMode}}
+}
+
+// validateListeners rejects listeners without an address or with unknown route groups
+func validateListeners(listeners []ListenerConfig) error {
+	for _, listener := range listeners {
+		if listener.Address == "" {
+			return fmt.Errorf("listener without address")
+		}
+		if len(listener.Routes) == 0 {
+			return fmt.Errorf("listener %s serves no routes", listener.Address)
+		}
+		for _, route := range listener.Routes {
+			if !slices.Contains(routeGroups, route) {
+				return fmt.Errorf("listener %s: unknown route group %q, expected one of %v", listener.Address, route, routeGroups)
+			}
+		}
+		if _, err := socketMode(listener.Mode); err != nil {
+			return fmt.Errorf("listener %s: %w", listener.Address, err)
+		}
+	}
+	return nil
+}
+
+// socketMode parses the octal file mode of a unix socket listener
+func socketMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return defaultSocketMode, nil
+	}
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode %q, expected octal permissions like 0660", mode)
+	}
+	return os.FileMode(perm), nil
+}
+
+// listen binds a TCP address, or a unix socket for "unix:<path>" with the given file mode
+func listen(address, mode string) (gonet.Listener, error) {
+	path, ok := strings.CutPrefix(address, unixAddressPrefix)
+	if !ok {
+		return gonet.Listen("tcp", address)
+	}
+	// a socket left behind by a crash makes the bind fail, closing the listener removes it on a clean shutdown
+	if info, err := os.Stat(path); err == nil && info.Mode().Type() == os.ModeSocket {
+		os.Remove(path)
+	}
+	listener, err := gonet.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	perm, _ := socketMode(mode) // validated on load
+	if err := os.Chmod(path, perm); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// serveHTTP starts every configured listener and blocks until all of them stop.
+// The ports are bound before serving starts, so they can be privileged ports when the server drops root afterwards.
+// A tls or listener failure is returned without marking the ports bound, the server must not run without its frontend.
+func serveHTTP() error {
+	cfg := appConfig()
+
+	var (
+		tlsConfig        *tls.Config
+		challengeHandler http.Handler
+	)
+	if tlsEnabled(cfg) {
+		var err error
+		tlsConfig, challengeHandler, err = newTLSConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to configure tls: %w", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	start := func(server *http.Server, mode string) error {
+		listener, err := listen(server.Addr, mode)
+		if err != nil {
+			return fmt.Errorf("failed to start http server on %s: %w", server.Addr, err)
+		}
+		httpServers = append(httpServers, server)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if server.TLSConfig != nil {
+				err = server.ServeTLS(listener, "", "")
+			} else {
+				err = server.Serve(listener)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Errorf("Failed to serve http on %s: %v", server.Addr, err)
+			}
+		}()
+		return nil
+	}
+
+	httpServersLock.Lock()
+	for _, listener := range listenerConfigs(cfg) {
+		server := &http.Server{
+			Addr:              listener.Address,
+			Handler:           newRouter(listener.Routes),
+			Protocols:         new(http.Protocols),
+			ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+			ReadTimeout:       cfg.HTTP.ReadTimeout,
+			IdleTimeout:       cfg.HTTP.IdleTimeout,
+			MaxHeaderBytes:    cfg.HTTP.MaxHeaderBytes,
+		}
+		// HTTP/2 is negotiated over TLS, cleartext HTTP/2 is only spoken if enabled for proxies supporting it
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetHTTP2(true)
+		if listener.TLS {
+			server.TLSConfig = tlsConfig
+		} else {
+			server.Protocols.SetUnencryptedHTTP2(cfg.HTTP.H2C)
+		}
+		if err := start(server, listener.Mode); err != nil {
+			httpServersLock.Unlock()
+			return err
+		}
+	}
+	if challengeHandler != nil && cfg.TLS.Autocert.HTTPAddress != "" {
+		// answers ACME HTTP-01 challenges and redirects everything else to https
+		err := start(&http.Server{
+			Addr:              cfg.TLS.Autocert.HTTPAddress,
+			Handler:           challengeHandler,
+			ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+			ReadTimeout:       cfg.HTTP.ReadTimeout,
+			IdleTimeout:       cfg.HTTP.IdleTimeout,
+			MaxHeaderBytes:    cfg.HTTP.MaxHeaderBytes,
+		}, "")
+		if err != nil {
+			httpServersLock.Unlock()
+			return err
+		}
+	}
+	httpServersLock.Unlock()
+	markHTTPBound()
+
+	wg.Wait()
+	return nil
+}
+
+// shutdownHTTP gracefully stops every listener
+func shutdownHTTP(ctx context.Context) {
+	httpServersLock.Lock()
+	defer httpServersLock.Unlock()
+
+	for _, server := range httpServers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Errorf("Failed to shutdown http server on %s: %v", server.Addr, err)
+		}
+	}
+}
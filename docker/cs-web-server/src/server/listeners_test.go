@@ -0,0 +1,39 @@
+package main
+
+import (
+	gonet "net"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeHTTPFailsWhenAListenerCannotBind(t *testing.T) {
+	busy, err := gonet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	cfg := useTestConfig(t)
+	cfg.HTTP.Listeners = []ListenerConfig{{Address: busy.Addr().String(), Routes: []string{"health"}}}
+
+	if err := serveHTTP(); err == nil {
+		t.Fatal("serveHTTP succeeded on a busy address")
+	}
+	select {
+	case <-httpBound:
+		t.Fatal("ports marked bound after a listener failed")
+	default:
+	}
+}
+
+func TestServeHTTPFailsWithoutCertificate(t *testing.T) {
+	cfg := useTestConfig(t)
+	dir := t.TempDir()
+	cfg.TLS.CertFile = filepath.Join(dir, "missing.crt")
+	cfg.TLS.KeyFile = filepath.Join(dir, "missing.key")
+	cfg.HTTP.Listeners = []ListenerConfig{{Address: "127.0.0.1:0", Routes: []string{"health"}, TLS: true}}
+
+	if err := serveHTTP(); err == nil {
+		t.Fatal("serveHTTP succeeded without a certificate")
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+import "syscall"
+
+// dupTo makes newfd a copy of oldfd, linux/arm64 has no dup2
+func dupTo(oldfd, newfd int) error {
+	return syscall.Dup3(oldfd, newfd, 0)
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// captureFileDescriptor points fd at a new pipe, so output written by the engine's C code is captured as well.
+// It returns the original target of fd and the read end of the pipe.
+func captureFileDescriptor(fd int) (*os.File, *os.File, error) {
+	original, err := syscall.Dup(fd)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		syscall.Close(original)
+		return nil, nil, err
+	}
+	defer writer.Close()
+	if err := dupTo(int(writer.Fd()), fd); err != nil {
+		syscall.Close(original)
+		reader.Close()
+		return nil, nil, err
+	}
+	return os.NewFile(uintptr(original), fmt.Sprintf("fd%d", fd)), reader, nil
+}
+
+// releaseFileDescriptor closes a captured fd, its reader sees EOF once no other copy of the pipe is open
+func releaseFileDescriptor(fd int) {
+	syscall.Close(fd)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// stdHandles maps the captured file descriptors to their standard handle and Go file
+var stdHandles = map[int]struct {
+	handle int
+	file   **os.File
+}{
+	1: {syscall.STD_OUTPUT_HANDLE, &os.Stdout},
+	2: {syscall.STD_ERROR_HANDLE, &os.Stderr},
+}
+
+var (
+	// capturedWriters are the write ends of the pipes replacing the standard handles
+	capturedWriters = map[int]*os.File{}
+
+	procSetStdHandle = syscall.NewLazyDLL("kernel32.dll").NewProc("SetStdHandle")
+)
+
+// captureFileDescriptor replaces the standard handle of fd with a new pipe.
+// Windows has no dup2 for handles the C runtime already opened, so only output written through the standard handles
+// and os.Stdout/os.Stderr after this call is captured.
+func captureFileDescriptor(fd int) (*os.File, *os.File, error) {
+	std, ok := stdHandles[fd]
+	if !ok {
+		return nil, nil, fmt.Errorf("fd %d is not a standard handle", fd)
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok, _, err := procSetStdHandle.Call(uintptr(std.handle), writer.Fd()); ok == 0 {
+		reader.Close()
+		writer.Close()
+		return nil, nil, err
+	}
+	original := *std.file
+	*std.file = writer
+	capturedWriters[fd] = writer
+	return original, reader, nil
+}
+
+// releaseFileDescriptor closes the pipe replacing a standard handle, its reader sees EOF
+func releaseFileDescriptor(fd int) {
+	if writer, ok := capturedWriters[fd]; ok {
+		writer.Close()
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logFileTimeFormat = "20060102-150405"
+	// logFlushTimeout bounds how long exiting waits for captured output to reach the log file
+	logFlushTimeout = time.Second
+)
+
+var (
+	capturedFds []int
+	logCopiers  sync.WaitGroup
+)
+
+// rotatingFile is a log file rotated by size and age, keeping a limited number of rotated files
+type rotatingFile struct {
+	lock     sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	// errors receives rotation failures, it's the original stderr as the captured one is drained by the writer itself
+	errors io.Writer
+}
+
+func openRotatingFile(path string) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size, r.openedAt = f, info.Size(), time.Now()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cfg := appConfig().Log
+	maxSize := int64(cfg.MaxSizeMB) << 20
+	if r.size > 0 && ((maxSize > 0 && r.size+int64(len(p)) > maxSize) ||
+		(cfg.RotateInterval > 0 && time.Since(r.openedAt) >= cfg.RotateInterval)) {
+		if err := r.rotate(); err != nil {
+			// keep writing to the current file rather than losing logs
+			if r.errors != nil {
+				fmt.Fprintf(r.errors, "Failed to rotate log file: %v\n", err)
+			}
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) setErrorOutput(w io.Writer) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.errors = w
+}
+
+// rotate renames the current file to name-YYYYMMDD-HHMMSS.ext and starts a new one
+func (r *rotatingFile) rotate() error {
+	extension := filepath.Ext(r.path)
+	rotated := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, extension), time.Now().Format(logFileTimeFormat), extension)
+	if err := os.Rename(r.path, rotated); err != nil {
+		return err
+	}
+	previous := r.file
+	if err := r.open(); err != nil {
+		r.file = previous
+		return err
+	}
+	previous.Close()
+	go pruneLogFiles(r.path)
+	return nil
+}
+
+// rotatedLogFiles returns the rotated files of a log file, oldest first
+func rotatedLogFiles(path string) ([]string, error) {
+	extension := filepath.Ext(path)
+	files, err := filepath.Glob(strings.TrimSuffix(path, extension) + "-*" + extension)
+	if err != nil {
+		return nil, err
+	}
+	// the timestamp suffix sorts chronologically
+	slices.Sort(files)
+	return files, nil
+}
+
+// pruneLogFiles removes rotated files beyond the retention count or age
+func pruneLogFiles(path string) {
+	cfg := appConfig().Log
+	files, err := rotatedLogFiles(path)
+	if err != nil {
+		return
+	}
+	for i, file := range files {
+		expired := cfg.MaxBackups > 0 && i < len(files)-cfg.MaxBackups
+		if info, err := os.Stat(file); err == nil && cfg.MaxAge > 0 && time.Since(info.ModTime()) > cfg.MaxAge {
+			expired = true
+		}
+		if expired {
+			if err := os.Remove(file); err != nil {
+				log.Errorf("Failed to remove old log file: %v", err)
+			}
+		}
+	}
+}
+
+// setupFileLogging copies everything written to stdout and stderr, including the engine's output,
+// into the configured log file while still passing it through to the container output
+func setupFileLogging() error {
+	path := appConfig().Log.File
+	if path == "" {
+		return nil
+	}
+	file, err := openRotatingFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	// the server's own loggers write to stderr, so stdout only carries engine output
+	sources := map[int]func([]byte) string{
+		int(os.Stdout.Fd()): func([]byte) string { return logSourceEngine },
+		int(os.Stderr.Fd()): stderrLogSource,
+	}
+	for fd, source := range sources {
+		out, err := teeFileDescriptor(fd, file, source)
+		if err != nil {
+			return fmt.Errorf("failed to capture output: %w", err)
+		}
+		if fd == int(os.Stderr.Fd()) {
+			file.setErrorOutput(out)
+		}
+	}
+	pruneLogFiles(path)
+	return nil
+}
+
+// stderrLogSource tells the server's log lines apart from the engine's errors
+func stderrLogSource(line []byte) string {
+	match := serverLogLevel.FindSubmatch(line)
+	switch {
+	case match == nil:
+		return logSourceEngineStderr
+	case string(match[1]) == "http":
+		return logSourceHTTP
+	default:
+		return logSourceServer
+	}
+}
+
+// lineDeduper collapses consecutive identical lines of a source, engine spam like missing resources
+// would otherwise fill the log file with the same line
+type lineDeduper struct {
+	last     []byte
+	source   string
+	repeated int
+}
+
+// filter returns the lines to write for line, holding back repeats of the previous line
+func (d *lineDeduper) filter(source string, line []byte) []logLine {
+	if source == d.source && bytes.Equal(line, d.last) {
+		d.repeated++
+		return nil
+	}
+	lines := d.flush()
+	d.last, d.source = slices.Clone(line), source
+	return append(lines, logLine{source, line})
+}
+
+// flush returns the summary of the repeats held back so far
+func (d *lineDeduper) flush() []logLine {
+	if d.repeated == 0 {
+		return nil
+	}
+	summary := logLine{d.source, []byte(fmt.Sprintf("last message repeated %d times\n", d.repeated))}
+	d.repeated = 0
+	return []logLine{summary}
+}
+
+type logLine struct {
+	source string
+	text   []byte
+}
+
+// teeFileDescriptor replaces fd with a pipe whose lines are written to both the original fd and w, it returns the original fd.
+// Lines written to w are prefixed with an RFC 3339 timestamp, as the engine's output has none, and their source.
+// Engine color codes are stripped and secrets masked in the file, the container output keeps them.
+// With log.dedupe, repeated lines are only written to the file once followed by a count.
+func teeFileDescriptor(fd int, w io.Writer, source func([]byte) string) (io.Writer, error) {
+	out, reader, err := captureFileDescriptor(fd)
+	if err != nil {
+		return nil, err
+	}
+	capturedFds = append(capturedFds, fd)
+
+	logCopiers.Add(1)
+	go func() {
+		defer logCopiers.Done()
+		lines := bufio.NewReader(reader)
+		deduper := &lineDeduper{}
+		write := func(lines []logLine) {
+			for _, line := range lines {
+				w.Write(append([]byte(time.Now().Format(time.RFC3339)+" "+line.source+" "), line.text...))
+			}
+		}
+		for {
+			line, err := lines.ReadBytes('\n')
+			if len(line) > 0 {
+				out.Write(line)
+				src := source(line)
+				if src == logSourceEngine || src == logSourceEngineStderr {
+					line = stripColorCodes(line)
+				}
+				line = scrubLogLine(line)
+				if appConfig().Log.Dedupe {
+					write(deduper.filter(src, line))
+				} else {
+					write(deduper.flush())
+					write([]logLine{{src, line}})
+				}
+			}
+			if err != nil {
+				write(deduper.flush())
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// flushFileLogging closes the captured stdout and stderr and waits until their remaining output has been written
+func flushFileLogging() {
+	if len(capturedFds) == 0 {
+		return
+	}
+	for _, fd := range capturedFds {
+		releaseFileDescriptor(fd)
+	}
+	done := make(chan struct{})
+	go func() {
+		logCopiers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(logFlushTimeout):
+	}
+}
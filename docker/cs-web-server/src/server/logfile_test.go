@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotationFailureGoesToErrorOutput(t *testing.T) {
+	cfg := useTestConfig(t)
+	cfg.Log.RotateInterval = time.Nanosecond
+	file, err := openRotatingFile(filepath.Join(t.TempDir(), "server.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errors bytes.Buffer
+	file.setErrorOutput(&errors)
+
+	if _, err := file.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	// the rename of a removed file fails, the write still goes to the open file
+	if err := os.Remove(file.path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(errors.String(), "Failed to rotate log file") {
+		t.Fatalf("rotation failure not reported: %q", errors.String())
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pion/logging"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const serverLogScope = "sfu-ws"
+
+// logLevelValues maps the level names of the API to the logger levels
+var logLevelValues = map[string]logging.LogLevel{
+	"trace": logging.LogLevelTrace,
+	"debug": logging.LogLevelDebug,
+	"info":  logging.LogLevelInfo,
+	"warn":  logging.LogLevelWarn,
+	"error": logging.LogLevelError,
+}
+
+// logLevelState is the runtime level of the server logger, optionally reverting to the default after a while
+type logLevelState struct {
+	lock    sync.Mutex
+	level   string
+	resetAt time.Time
+	timer   *time.Timer
+}
+
+var serverLogLevelState logLevelState
+
+type logLevelStatus struct {
+	Level   string     `json:"level"`
+	Default string     `json:"default"`
+	ResetAt *time.Time `json:"reset_at,omitempty"`
+}
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+	// Duration after which the default level is restored, empty keeps the level until the next restart
+	Duration string `json:"duration"`
+}
+
+// defaultLogLevel returns the server logger's level set through the PION_LOG_* environment variables.
+// Unless its scope is set explicitly it's at least info, pion's error default would hide maintenance,
+// shutdown, reload and challenge messages.
+func defaultLogLevel() string {
+	factory := logging.NewDefaultLoggerFactory()
+	level, ok := factory.ScopeLevels[serverLogScope]
+	if !ok {
+		level = max(factory.DefaultLogLevel, logging.LogLevelInfo)
+	}
+	for name, value := range logLevelValues {
+		if value == level {
+			return name
+		}
+	}
+	return "error"
+}
+
+// set changes the server logger's level, restoring the default after duration if it's positive
+func (s *logLevelState) set(level string, duration time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if setter, ok := log.(interface{ SetLevel(logging.LogLevel) }); ok {
+		setter.SetLevel(logLevelValues[level])
+	}
+	s.level = level
+	s.resetAt = time.Time{}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if duration > 0 {
+		s.resetAt = time.Now().Add(duration)
+		s.timer = time.AfterFunc(duration, func() {
+			s.set(defaultLogLevel(), 0)
+			log.Warnf("Log level reset to default")
+		})
+	}
+}
+
+func (s *logLevelState) status() logLevelStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	status := logLevelStatus{Level: s.level, Default: defaultLogLevel()}
+	if status.Level == "" {
+		status.Level = status.Default
+	}
+	if !s.resetAt.IsZero() {
+		resetAt := s.resetAt
+		status.ResetAt = &resetAt
+	}
+	return status
+}
+
+// logLevelHandler reports (GET), changes (PUT) or resets (DELETE) the server log level
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		req := logLevelRequest{}
+		if err := decodeJSON(w, r, &req); err != nil && err != io.EOF {
+			writeBodyError(w, err)
+			return
+		}
+		if _, ok := logLevelValues[req.Level]; !ok {
+			http.Error(w, fmt.Sprintf("level must be one of %v", logLevels), http.StatusBadRequest)
+			return
+		}
+		var duration time.Duration
+		if req.Duration != "" {
+			var err error
+			if duration, err = time.ParseDuration(req.Duration); err != nil || duration < 0 {
+				http.Error(w, "duration must be a positive duration like 15m", http.StatusBadRequest)
+				return
+			}
+		}
+		serverLogLevelState.set(req.Level, duration)
+		log.Warnf("Log level set to %s", req.Level)
+	case http.MethodDelete:
+		serverLogLevelState.set(defaultLogLevel(), 0)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, serverLogLevelState.status())
+}
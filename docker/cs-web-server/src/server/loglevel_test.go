@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestDefaultLogLevel(t *testing.T) {
+	tests := []struct {
+		env, value, want string
+	}{
+		{"", "", "info"},
+		{"PION_LOG_DEBUG", "all", "debug"},
+		{"PION_LOG_WARN", "sfu-ws", "warn"},
+		{"PION_LOG_TRACE", "ice,sfu-ws", "trace"},
+	}
+	for _, test := range tests {
+		t.Run(test.env+"="+test.value, func(t *testing.T) {
+			if test.env != "" {
+				t.Setenv(test.env, test.value)
+			}
+			if got := defaultLogLevel(); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLogsLimit = 100
+	maxLogsLimit     = 1000
+)
+
+// logLevels in increasing severity
+var logLevels = []string{"trace", "debug", "info", "warn", "error"}
+
+// Sources of log lines
+const (
+	logSourceEngine       = "engine"        // engine stdout
+	logSourceEngineStderr = "engine-stderr" // engine stderr
+	logSourceServer       = "server"        // server loggers
+	logSourceHTTP         = "http"          // access log
+)
+
+var logSources = []string{logSourceEngine, logSourceEngineStderr, logSourceServer, logSourceHTTP}
+
+// serverLogLevel matches the scope and level prefix of lines written by the server's loggers
+var serverLogLevel = regexp.MustCompile(`^(\S+) (TRACE|DEBUG|INFO|WARNING|ERROR): `)
+
+// engineColorCode matches the ^0-^9 color codes of engine console output
+var engineColorCode = regexp.MustCompile(`\^[0-9]`)
+
+// engineLogPrefixes are the message prefixes the engine uses (S_ERROR, S_WARN, S_NOTE, S_USAGE) and their levels
+var engineLogPrefixes = []struct {
+	prefix string
+	level  string
+}{
+	{"error:", "error"},
+	{"warning:", "warn"},
+	{"note:", "info"},
+	{"usage:", "info"},
+}
+
+// stripColorCodes removes engine color codes from a line
+func stripColorCodes(line []byte) []byte {
+	return engineColorCode.ReplaceAll(line, nil)
+}
+
+// engineLogLevel derives the level of an engine line from its prefix.
+// Unprefixed lines are informational on stdout and warnings on stderr.
+func engineLogLevel(source, message string) string {
+	lower := strings.ToLower(strings.TrimSpace(message))
+	for _, p := range engineLogPrefixes {
+		if strings.HasPrefix(lower, p.prefix) {
+			return p.level
+		}
+	}
+	if source == logSourceEngineStderr {
+		return "warn"
+	}
+	return "info"
+}
+
+// logEntry is a single line of the log file
+type logEntry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Level   string    `json:"level,omitempty"`
+	Message string    `json:"message"`
+}
+
+type logsResponse struct {
+	Entries []logEntry `json:"entries"`
+	// Next is the cursor to continue from, empty once the end of the log was reached
+	Next string `json:"next,omitempty"`
+}
+
+// logQuery filters log entries
+type logQuery struct {
+	text   string
+	source string
+	from   time.Time
+	to     time.Time
+	level  int
+}
+
+// parseLogLine splits a log file line into its timestamp, source, level and message
+func parseLogLine(line string) (logEntry, bool) {
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 || !slices.Contains(logSources, fields[1]) {
+		return logEntry{}, false
+	}
+	t, err := time.Parse(time.RFC3339, fields[0])
+	if err != nil {
+		return logEntry{}, false
+	}
+	entry := logEntry{Time: t, Source: fields[1], Message: fields[2]}
+	switch entry.Source {
+	case logSourceEngine, logSourceEngineStderr:
+		entry.Level = engineLogLevel(entry.Source, entry.Message)
+	default:
+		if match := serverLogLevel.FindStringSubmatch(entry.Message); match != nil {
+			entry.Level = strings.TrimSuffix(strings.ToLower(match[2]), "ing")
+		}
+	}
+	return entry, true
+}
+
+func (q logQuery) matches(entry logEntry) bool {
+	if !q.from.IsZero() && entry.Time.Before(q.from) {
+		return false
+	}
+	if !q.to.IsZero() && entry.Time.After(q.to) {
+		return false
+	}
+	if q.source != "" && entry.Source != q.source {
+		return false
+	}
+	if q.level > 0 && slices.Index(logLevels, entry.Level) < q.level {
+		return false
+	}
+	return q.text == "" || strings.Contains(strings.ToLower(entry.Message), q.text)
+}
+
+// logFiles returns the rotated log files followed by the current one, oldest first
+func logFiles() ([]string, error) {
+	path := appConfig().Log.File
+	files, err := rotatedLogFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	return append(files, path), nil
+}
+
+// searchLogs returns up to limit entries matching the query in chronological order, starting at the cursor.
+// The cursor is the start of a log file and the offset of the next line to read in it.
+func searchLogs(query logQuery, cursor string, limit int) (logsResponse, error) {
+	response := logsResponse{Entries: []logEntry{}}
+	files, err := logFiles()
+	if err != nil {
+		return response, err
+	}
+
+	start, offset := 0, int64(0)
+	if cursor != "" {
+		var ok bool
+		if start, offset, ok = resolveLogCursor(files, cursor); !ok {
+			return response, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	for i := start; i < len(files); i++ {
+		if i != start {
+			offset = 0
+		}
+		// rotated files last written before the start of the range can't contain matches
+		if info, err := os.Stat(files[i]); err != nil || (!query.from.IsZero() && info.ModTime().Before(query.from)) {
+			continue
+		}
+
+		next, err := searchLogFile(files[i], offset, query, limit, &response.Entries)
+		if err != nil {
+			return response, err
+		}
+		if next >= 0 {
+			fileStart, _ := logFileStart(files[i])
+			response.Next = fmt.Sprintf("%s:%d", fileStart, next)
+			return response, nil
+		}
+	}
+	return response, nil
+}
+
+// resolveLogCursor returns the index in files and the offset a cursor points to.
+// Rotation renames the current file, so cursors name a file by the time of its first line instead of its name.
+// Files starting in the same second resolve to the oldest, repeating entries rather than skipping them.
+func resolveLogCursor(files []string, cursor string) (int, int64, bool) {
+	fileStart, position, ok := strings.Cut(cursor, ":")
+	offset, err := strconv.ParseInt(position, 10, 64)
+	if !ok || err != nil || offset < 0 {
+		return 0, 0, false
+	}
+	for i, file := range files {
+		if start, ok := logFileStart(file); !ok || start != fileStart {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil || offset > info.Size() {
+			return 0, 0, false
+		}
+		return i, offset, true
+	}
+	return 0, 0, false
+}
+
+// logFileStart returns the time of the first line of a log file in the format of rotated file names
+func logFileStart(file string) (string, bool) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil {
+		return "", false
+	}
+	timestamp, _, _ := strings.Cut(line, " ")
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return "", false
+	}
+	return t.UTC().Format(logFileTimeFormat), true
+}
+
+// searchLogFile appends matching entries of a file starting at offset until entries holds limit.
+// It returns the offset to continue from, or -1 if the whole file was read.
+func searchLogFile(file string, offset int64, query logQuery, limit int, entries *[]logEntry) (int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return -1, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return -1, err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// an incomplete last line is still being written
+			return -1, nil
+		}
+		offset += int64(len(line))
+		if entry, ok := parseLogLine(strings.TrimRight(line, "\r\n")); ok && query.matches(entry) {
+			*entries = append(*entries, entry)
+			if len(*entries) == limit {
+				return offset, nil
+			}
+		}
+	}
+}
+
+// logsHandler searches the log files: GET /v1/logs?query=&from=&to=&level=&limit=&cursor=
+func logsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if appConfig().Log.File == "" {
+		http.Error(w, "log file is not configured", http.StatusNotFound)
+		return
+	}
+
+	params := r.URL.Query()
+	query := logQuery{text: strings.ToLower(params.Get("query")), source: params.Get("source")}
+	if query.source != "" && !slices.Contains(logSources, query.source) {
+		http.Error(w, fmt.Sprintf("source must be one of %v", logSources), http.StatusBadRequest)
+		return
+	}
+	for name, t := range map[string]*time.Time{"from": &query.from, "to": &query.to} {
+		if value := params.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s must be an RFC 3339 time", name), http.StatusBadRequest)
+				return
+			}
+			*t = parsed
+		}
+	}
+	if level := params.Get("level"); level != "" {
+		query.level = slices.Index(logLevels, strings.ToLower(level))
+		if query.level < 0 {
+			http.Error(w, fmt.Sprintf("level must be one of %v", logLevels), http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultLogsLimit
+	if value := params.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, maxLogsLimit)
+	}
+
+	response, err := searchLogs(query, params.Get("cursor"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// logsDownloadHandler streams the current log file, and the rotated ones with ?rotated=true, as a .tar.gz archive
+func logsDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if appConfig().Log.File == "" {
+		http.Error(w, "log file is not configured", http.StatusNotFound)
+		return
+	}
+
+	files := []string{appConfig().Log.File}
+	if rotated, _ := strconv.ParseBool(r.URL.Query().Get("rotated")); rotated {
+		var err error
+		if files, err = logFiles(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="logs-%s.tar.gz"`, time.Now().Format(logFileTimeFormat)))
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	for _, file := range files {
+		if err := addLogFile(archive, file); err != nil {
+			// headers are already sent, the truncated archive tells the client something went wrong
+			log.Errorf("Failed to add %s to log archive: %v", file, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Errorf("Failed to write log archive: %v", err)
+		return
+	}
+	if err := gz.Close(); err != nil {
+		log.Errorf("Failed to write log archive: %v", err)
+	}
+}
+
+// addLogFile adds a log file to the archive as it is now, the current file keeps growing while it's read
+func addLogFile(archive *tar.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.CopyN(archive, f, info.Size())
+	return err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func writeLogFile(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func logMessages(entries []logEntry) []string {
+	messages := []string{}
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	return messages
+}
+
+func TestSearchLogsCursorAcrossRotation(t *testing.T) {
+	cfg := useTestConfig(t)
+	dir := t.TempDir()
+	cfg.Log.File = filepath.Join(dir, "server.log")
+	writeLogFile(t, cfg.Log.File,
+		"2026-01-20T12:00:00Z engine first",
+		"2026-01-20T12:00:01Z engine second",
+		"2026-01-20T12:00:02Z engine third",
+	)
+
+	page, err := searchLogs(logQuery{}, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages := logMessages(page.Entries); strings.Join(messages, ",") != "first" || page.Next == "" {
+		t.Fatalf("first page: %v, next %q", messages, page.Next)
+	}
+
+	// the current file is rotated and a new one started before the next page is requested
+	if err := os.Rename(cfg.Log.File, filepath.Join(dir, "server-20260120-120005.log")); err != nil {
+		t.Fatal(err)
+	}
+	writeLogFile(t, cfg.Log.File, "2026-01-20T12:00:05Z engine fourth")
+
+	page, err = searchLogs(logQuery{}, page.Next, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages := logMessages(page.Entries); strings.Join(messages, ",") != "second,third,fourth" {
+		t.Fatalf("next page after rotation: %v", messages)
+	}
+}
+
+func TestSearchLogsInvalidCursor(t *testing.T) {
+	cfg := useTestConfig(t)
+	cfg.Log.File = filepath.Join(t.TempDir(), "server.log")
+	writeLogFile(t, cfg.Log.File, "2026-01-20T12:00:00Z engine first")
+	size := len("2026-01-20T12:00:00Z engine first\n")
+
+	for _, cursor := range []string{
+		"server.log:0",
+		"20260120-120000",
+		"20260120-120000:",
+		"20260120-120000:-1",
+		"20260120-120000:" + strings.Repeat("9", 20),
+		"20260120-120000:" + strconv.Itoa(size+1),
+		"20260120-120001:0",
+	} {
+		if _, err := searchLogs(logQuery{}, cursor, 10); err == nil {
+			t.Errorf("cursor %q accepted", cursor)
+		}
+	}
+	for _, cursor := range []string{"20260120-120000:0", "20260120-120000:" + strconv.Itoa(size)} {
+		if _, err := searchLogs(logQuery{}, cursor, 10); err != nil {
+			t.Errorf("cursor %q rejected: %v", cursor, err)
+		}
+	}
+}
@@ -1,11 +1,62 @@
 package main
 
-import goxash3d_fwgs "github.com/yohimik/goxash3d-fwgs/pkg"
+import (
+	"fmt"
+	"os"
+)
 
 func main() {
-	goxash3d_fwgs.DefaultXash3D.Net = net
+	// operational commands like --check run instead of the server
+	if code, ok := runCommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+	// the configuration is loaded before anything else, the self-check only reports on it
+	cfg, err := readConfig()
+	if err == nil {
+		err = applyConfig(cfg)
+	}
+	if !selfCheck(os.Stderr, cfg, err, false) {
+		os.Exit(1)
+	}
+	if err := setupFileLogging(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// --replay <file> feeds a packet capture to the engine and exits
+	var replay []capturedPacket
+	if path, args, ok := replayFile(os.Args); ok {
+		os.Args = args
+		packets, err := loadCapture(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to load the capture:", err)
+			os.Exit(1)
+		}
+		replay = packets
+	}
+	os.Args = append(os.Args, tickRateArgs(cfg)...)
 
 	go runSFU()
+	go handleShutdownSignals()
+	go handleReloadSignals()
+	go runWatchdog()
+	go runScheduledRestarts()
+	go runFrameRateMonitor()
+	if replay != nil {
+		go runReplay(replay)
+	}
+	// the engine only starts once root is dropped, so the old game code never runs privileged
+	if user := appConfig().Server.User; user != "" {
+		<-httpBound
+		if err := dropPrivileges(user); err != nil {
+			log.Errorf("Failed to drop privileges to %s: %v", user, err)
+			shutdown(1)
+		}
+		log.Infof("Running as %s", user)
+	}
 
-	goxash3d_fwgs.DefaultXash3D.SysStart()
+	code := runEngine(net)
+	if code != 0 {
+		engineCrashed(fmt.Sprintf("engine exited with code %d", code), code)
+	}
+	shutdown(code)
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultMaintenanceMessage = "Server is under maintenance, please try again later"
+
+// maintenanceState tracks whether new peers are admitted
+type maintenanceState struct {
+	sync.RWMutex
+	enabled bool
+	message string
+	since   time.Time
+}
+
+var maintenance = &maintenanceState{}
+
+type maintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+type maintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	Peers   int        `json:"peers"`
+	Drained bool       `json:"drained"`
+}
+
+// set switches maintenance mode, keeping the original start time while it stays enabled
+func (m *maintenanceState) set(enabled bool, message string) {
+	m.Lock()
+	defer m.Unlock()
+
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	if enabled && !m.enabled {
+		m.since = time.Now()
+	}
+	m.enabled = enabled
+	m.message = message
+}
+
+// check returns the maintenance message if new peers must be rejected
+func (m *maintenanceState) check() (string, bool) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.message, m.enabled
+}
+
+// status reports maintenance mode together with the drain progress
+func (m *maintenanceState) status() maintenanceStatus {
+	peers := activePeerCount()
+
+	m.RLock()
+	defer m.RUnlock()
+
+	s := maintenanceStatus{
+		Enabled: m.enabled,
+		Peers:   peers,
+		Drained: m.enabled && peers == 0,
+	}
+	if m.enabled {
+		since := m.since
+		s.Message = m.message
+		s.Since = &since
+	}
+	return s
+}
+
+// maintenanceHandler reports (GET) or switches (POST) maintenance mode
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		req := maintenanceRequest{}
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		maintenance.set(req.Enabled, req.Message)
+		log.Infof("Maintenance mode enabled: %v", req.Enabled)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, maintenance.status())
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// pollIntervalBuckets are the upper bounds of the engine poll interval histogram in seconds
+var pollIntervalBuckets = []float64{0.001, 0.002, 0.005, 0.01, 0.02, 0.05, 0.1, 0.25, 0.5, 1}
+
+var (
+	enginePollTimes = newPollHistogram(pollIntervalBuckets)
+	// adminAuthFailures counts admin API requests rejected for a missing or wrong token
+	adminAuthFailures atomic.Uint64
+)
+
+// pollHistogram records the time between the engine's empty network polls.
+// The bindings have no frame hook, but the engine reads until its queue is empty at least once per frame,
+// so the intervals approximate frame times and never exceed them.
+// Polls run on the engine thread only, the atomics make the counters safe to read while scraping.
+type pollHistogram struct {
+	bounds  []float64
+	buckets []atomic.Uint64 // one per bound plus +Inf, not cumulative
+	sum     atomic.Int64    // nanoseconds
+	count   atomic.Uint64
+	last    atomic.Int64
+}
+
+func newPollHistogram(bounds []float64) *pollHistogram {
+	return &pollHistogram{bounds: bounds, buckets: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+// poll records an empty poll at now (unix nanoseconds)
+func (h *pollHistogram) poll(now int64) {
+	last := h.last.Swap(now)
+	if last == 0 {
+		return
+	}
+	duration := now - last
+	seconds := time.Duration(duration).Seconds()
+	i := 0
+	for i < len(h.bounds) && seconds > h.bounds[i] {
+		i++
+	}
+	h.buckets[i].Add(1)
+	h.sum.Add(duration)
+	h.count.Add(1)
+}
+
+// counts returns the current count of every bucket
+func (h *pollHistogram) counts() []uint64 {
+	counts := make([]uint64, len(h.buckets))
+	for i := range h.buckets {
+		counts[i] = h.buckets[i].Load()
+	}
+	return counts
+}
+
+// histogramQuantile estimates the q-quantile of non-cumulative bucket counts like Prometheus' histogram_quantile,
+// interpolating linearly within a bucket, values in the +Inf bucket are reported as the highest bound
+func histogramQuantile(q float64, bounds []float64, counts []uint64) float64 {
+	var total uint64
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := q * float64(total)
+	var cumulative uint64
+	for i, count := range counts {
+		if count == 0 || float64(cumulative+count) < rank {
+			cumulative += count
+			continue
+		}
+		if i == len(bounds) {
+			break
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = bounds[i-1]
+		}
+		return lower + (bounds[i]-lower)*(rank-float64(cumulative))/float64(count)
+	}
+	return bounds[len(bounds)-1]
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (m metricsWriter) metric(name, kind, help string, value any) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+}
+
+// labeled writes one sample per label value
+func (m metricsWriter) labeled(name, kind, help, label string, values []string, value func(i int) any) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for i, v := range values {
+		fmt.Fprintf(m.w, "%s{%s=%q} %v\n", name, label, v, value(i))
+	}
+}
+
+func (m metricsWriter) histogram(name, help string, h *pollHistogram) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.buckets[i].Load()
+		fmt.Fprintf(m.w, "%s_bucket{le=\"%v\"} %d\n", name, bound, cumulative)
+	}
+	cumulative += h.buckets[len(h.bounds)].Load()
+	fmt.Fprintf(m.w, "%s_bucket{le=\"+Inf\"} %d\n", name, cumulative)
+	fmt.Fprintf(m.w, "%s_sum %v\n%s_count %d\n", name, time.Duration(h.sum.Load()).Seconds(), name, h.count.Load())
+}
+
+// requireMetricsAuth asks for basic auth on the metrics route when metrics.username is configured
+func requireMetricsAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := appConfig().Metrics
+		if cfg.Username != "" {
+			username, password, ok := r.BasicAuth()
+			if !ok || subtle.ConstantTimeCompare([]byte(username), []byte(cfg.Username)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(password), []byte(cfg.Password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// metricsHandler exports engine, player and Go runtime metrics for Prometheus
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	m := metricsWriter{w}
+	m.histogram("webxash_engine_poll_interval_seconds",
+		"Time between empty engine network polls, at least one runs per frame, so it approximates the frame time.", enginePollTimes)
+	var heartbeat float64
+	if last := lastEngineHeartbeat(); !last.IsZero() {
+		heartbeat = float64(last.UnixNano()) / 1e9
+	}
+	m.metric("webxash_engine_last_poll_timestamp_seconds", "gauge", "Time of the last engine network poll.", heartbeat)
+	m.metric("webxash_engine_poll_rate", "gauge", "Empty engine network polls per second over the last 10 seconds, an upper bound of the frame rate.", achievedPollRate())
+	m.metric("webxash_engine_tick_rate", "gauge", "Frames per second the engine is configured to run at.", tickRate(appConfig()))
+	m.metric("webxash_peers", "gauge", "Connected WebRTC peers.", activePeerCount())
+	_, inMaintenance := maintenance.check()
+	m.metric("webxash_maintenance", "gauge", "Whether maintenance mode rejects new peers.", boolMetric(inMaintenance))
+	m.labeled("webxash_firewall_dropped_packets_total", "counter", "Packets from peers dropped by the firewall.", "reason",
+		dropReasonNames[:], func(i int) any { return firewallDrops[i].Load() })
+	m.metric("webxash_admin_auth_failures_total", "counter", "Admin API requests rejected for a missing or wrong token.", adminAuthFailures.Load())
+
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	m.metric("go_goroutines", "gauge", "Number of goroutines that currently exist.", runtime.NumGoroutine())
+	m.metric("go_memstats_heap_alloc_bytes", "gauge", "Heap bytes allocated and still in use.", memory.HeapAlloc)
+	m.metric("go_memstats_heap_sys_bytes", "gauge", "Heap bytes obtained from the system.", memory.HeapSys)
+	m.metric("go_memstats_sys_bytes", "gauge", "Bytes obtained from the system.", memory.Sys)
+	m.metric("go_gc_cycles_total", "counter", "Completed GC cycles.", memory.NumGC)
+	m.metric("go_gc_pause_seconds_total", "counter", "Total time the GC stopped the world.", time.Duration(memory.PauseTotalNs).Seconds())
+	m.metric("process_start_time_seconds", "gauge", "Start time of the process since the unix epoch.", startedAt.Unix())
+}
+
+func boolMetric(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	bounds := []float64{0.01, 0.02, 0.05}
+	tests := []struct {
+		q      float64
+		counts []uint64
+		want   float64
+	}{
+		{0.5, []uint64{0, 0, 0, 0}, 0},
+		{0.5, []uint64{10, 0, 0, 0}, 0.005},
+		{0.5, []uint64{0, 10, 0, 0}, 0.015},
+		{0.95, []uint64{90, 10, 0, 0}, 0.015},
+		{0.99, []uint64{90, 0, 0, 10}, 0.05},
+	}
+	for _, test := range tests {
+		if got := histogramQuantile(test.q, bounds, test.counts); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("histogramQuantile(%v, %v) = %v, want %v", test.q, test.counts, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// apiOperation is a method of an HTTP endpoint in the OpenAPI document
+type apiOperation struct {
+	method  string
+	path    string
+	group   string
+	summary string
+}
+
+// apiOperations documents the HTTP API registered in newRouter, static assets and signaling aren't part of it
+var apiOperations = []apiOperation{
+	{http.MethodGet, "/config", routePublic, "Engine arguments, console commands and library paths for the web client"},
+	{http.MethodGet, "/v1/ice", routePublic, "STUN/TURN servers with time-limited TURN credentials"},
+
+	{http.MethodGet, "/healthz", routeHealth, "Process is up"},
+	{http.MethodGet, "/readyz", routeHealth, "Server admits players"},
+	{http.MethodGet, "/livez", routeHealth, "Engine ran a frame recently"},
+
+	{http.MethodGet, "/v1/maintenance", routeAdmin, "Maintenance mode status and drain progress"},
+	{http.MethodPost, "/v1/maintenance", routeAdmin, "Enable or disable maintenance mode"},
+	{http.MethodGet, "/v1/restart", routeAdmin, "Pending restart status"},
+	{http.MethodPost, "/v1/restart", routeAdmin, "Restart now or once the server is empty"},
+	{http.MethodDelete, "/v1/restart", routeAdmin, "Cancel a pending restart"},
+	{http.MethodPost, "/v1/config/reload", routeAdmin, "Reload the configuration file"},
+	{http.MethodGet, "/v1/logs", routeAdmin, "Search the log files"},
+	{http.MethodGet, "/v1/logs/download", routeAdmin, "Download the log files as .tar.gz"},
+	{http.MethodGet, "/v1/loglevel", routeAdmin, "Current server log level"},
+	{http.MethodPut, "/v1/loglevel", routeAdmin, "Change the server log level, optionally for a limited time"},
+	{http.MethodDelete, "/v1/loglevel", routeAdmin, "Restore the default server log level"},
+	{http.MethodGet, "/v1/version", routeAdmin, "Server, engine and protocol versions and enabled features"},
+	{http.MethodGet, "/v1/status", routeAdmin, "Engine poll rate and poll interval quantiles, peers and maintenance mode"},
+	{http.MethodGet, "/v1/debug/goroutines", routeAdmin, "Stacks of all goroutines"},
+	{http.MethodPost, "/v1/debug/profile", routeAdmin, "Record and download a CPU or heap profile"},
+	{http.MethodPost, "/v1/debug/capture", routeAdmin, "Record and download the packets of a player"},
+
+	{http.MethodGet, "/metrics", routeMetrics, "Prometheus metrics"},
+}
+
+// routeSecurity is the authentication each route group asks for, as OpenAPI security schemes
+var routeSecurity = map[string]string{
+	routeAdmin:   "adminToken",
+	routeMetrics: "metricsBasicAuth",
+}
+
+// writeOpenAPI writes the OpenAPI 3 document of the HTTP API
+func writeOpenAPI(w io.Writer) error {
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations {
+		operation := map[string]any{
+			"summary":   op.summary,
+			"tags":      []string{op.group},
+			"responses": map[string]any{"200": map[string]string{"description": "OK"}},
+		}
+		if scheme, ok := routeSecurity[op.group]; ok {
+			operation["security"] = []map[string][]string{{scheme: {}}}
+		}
+		if paths[op.path] == nil {
+			paths[op.path] = map[string]any{}
+		}
+		paths[op.path][strings.ToLower(op.method)] = operation
+	}
+
+	document := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":   "cs-web-server",
+			"version": buildVersion().Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"securitySchemes": map[string]any{
+				"adminToken":       map[string]string{"type": "http", "scheme": "bearer", "description": "ADMIN_TOKEN"},
+				"metricsBasicAuth": map[string]string{"type": "http", "scheme": "basic", "description": "metrics.username and metrics.password, if set"},
+			},
+		},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
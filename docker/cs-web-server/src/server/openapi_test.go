@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := writeOpenAPI(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	document := struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Security []map[string][]string `json:"security"`
+		} `json:"paths"`
+	}{}
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.OpenAPI == "" {
+		t.Fatal("missing openapi version")
+	}
+	for _, op := range apiOperations {
+		operation, ok := document.Paths[op.path][strings.ToLower(op.method)]
+		if !ok {
+			t.Errorf("%s %s missing", op.method, op.path)
+			continue
+		}
+		if op.group == routeAdmin && (len(operation.Security) != 1 || operation.Security[0]["adminToken"] == nil) {
+			t.Errorf("%s %s doesn't require the admin token", op.method, op.path)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// dropPrivileges isn't supported outside unix, run the server as an unprivileged user instead
+func dropPrivileges(spec string) error {
+	return errors.New("not supported on this platform")
+}
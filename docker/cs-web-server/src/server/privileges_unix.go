@@ -0,0 +1,70 @@
+//go:build unix
+
+package main
+
+import (
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// dropPrivileges switches the process to spec: a user name or uid, optionally followed by ":<group>".
+// A user name also takes its supplementary groups, numeric ids drop all supplementary groups.
+func dropPrivileges(spec string) error {
+	uid, gid, groups, err := lookupUser(spec)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Setgroups(groups); err != nil {
+		return err
+	}
+	// the group has to change first, setgid isn't allowed anymore once root is dropped
+	if err := syscall.Setgid(gid); err != nil {
+		return err
+	}
+	return syscall.Setuid(uid)
+}
+
+func lookupUser(spec string) (uid, gid int, groups []int, err error) {
+	name, group, hasGroup := strings.Cut(spec, ":")
+	if id, err := strconv.Atoi(name); err == nil {
+		gid := id
+		if hasGroup {
+			if gid, err = strconv.Atoi(group); err != nil {
+				return 0, 0, nil, err
+			}
+		}
+		return id, gid, []int{}, nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if uid, err = strconv.Atoi(u.Uid); err != nil {
+		return 0, 0, nil, err
+	}
+	if gid, err = strconv.Atoi(u.Gid); err != nil {
+		return 0, 0, nil, err
+	}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	for _, id := range ids {
+		if group, err := strconv.Atoi(id); err == nil {
+			groups = append(groups, group)
+		}
+	}
+	if hasGroup {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+	return uid, gid, groups, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// peerPortBase is the port of the first slot, the port of a peer's engine address selects its slot
+const peerPortBase = 1000
+
+// connectionRegistry maps the engine addresses of peers to their data channels.
+// The port selects the slot and the IP is the player's session identity, which also tells a peer apart from the
+// previous owner of its slot, so packets the engine still sends to a disconnected peer never reach the next one.
+type connectionRegistry struct {
+	slots [256]atomic.Pointer[peerSlot]
+	free  *indexPool
+	// acquireLock serializes acquires, so two connections of one session can't miss each other
+	acquireLock sync.Mutex
+}
+
+// peerSlot is the engine address of a peer. It stays registered until every user released it:
+// the signaling handler and the read loop both hold a reference.
+type peerSlot struct {
+	addr     engineAddr
+	writer   atomic.Pointer[io.Writer]
+	refs     atomic.Int32
+	registry *connectionRegistry
+	// capture is set while an admin captures the peer's packets
+	capture atomic.Pointer[packetCapture]
+	// evicted is closed when a newer connection of the same session took over
+	evicted   chan struct{}
+	evictOnce sync.Once
+}
+
+var peers = &connectionRegistry{free: newIndexPool(256)}
+
+// acquire registers a new peer with the given engine IP, it fails when all slots are in use.
+// A session is used by one connection at a time: peers already registered with the IP are evicted,
+// so a reconnecting player replaces its stale connection.
+func (c *connectionRegistry) acquire(ip [4]byte) (*peerSlot, bool) {
+	c.acquireLock.Lock()
+	defer c.acquireLock.Unlock()
+
+	index, err := c.free.TryGet()
+	if err != nil {
+		return nil, false
+	}
+	for i := range c.slots {
+		if previous := c.slots[i].Load(); previous != nil && previous.addr.IP == ip {
+			previous.evict()
+		}
+	}
+	slot := &peerSlot{registry: c, addr: engineAddr{IP: ip, Port: peerPortBase + uint16(index)}, evicted: make(chan struct{})}
+	slot.refs.Store(1)
+	c.slots[index].Store(slot)
+	return slot, true
+}
+
+// slot returns the peer with exactly this address
+func (c *connectionRegistry) slot(addr engineAddr) *peerSlot {
+	index := int(addr.Port) - peerPortBase
+	if index < 0 || index >= len(c.slots) {
+		return nil
+	}
+	slot := c.slots[index].Load()
+	if slot == nil || slot.addr != addr {
+		return nil
+	}
+	return slot
+}
+
+// lookup returns the writer of the peer with exactly this address
+func (c *connectionRegistry) lookup(addr engineAddr) (*peerSlot, io.Writer) {
+	slot := c.slot(addr)
+	if slot == nil {
+		return nil, nil
+	}
+	writer := slot.writer.Load()
+	if writer == nil {
+		return nil, nil
+	}
+	return slot, *writer
+}
+
+// evict tells the peer's connection that a newer one of its session took over
+func (s *peerSlot) evict() {
+	s.evictOnce.Do(func() { close(s.evicted) })
+}
+
+// setWriter makes the peer reachable by the engine once its data channel is open
+func (s *peerSlot) setWriter(w io.Writer) {
+	s.writer.Store(&w)
+}
+
+// retain adds a reference, it fails once the last reference was released
+func (s *peerSlot) retain() bool {
+	for {
+		refs := s.refs.Load()
+		if refs == 0 {
+			return false
+		}
+		if s.refs.CompareAndSwap(refs, refs+1) {
+			return true
+		}
+	}
+}
+
+// release drops a reference, the last one unregisters the address and frees the slot
+func (s *peerSlot) release() {
+	if s.refs.Add(-1) != 0 {
+		return
+	}
+	s.writer.Store(nil)
+	index := s.addr.Port - peerPortBase
+	if s.registry.slots[index].CompareAndSwap(s, nil) {
+		s.registry.free.TryPut(uint8(index))
+	}
+}
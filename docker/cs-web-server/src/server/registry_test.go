@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestRegistryAcquireRelease(t *testing.T) {
+	registry := &connectionRegistry{free: newIndexPool(4)}
+	ip := [4]byte{10, 0, 0, 1}
+	slot, ok := registry.acquire(ip)
+	if !ok {
+		t.Fatal("acquire failed with free slots")
+	}
+	if slot.addr.IP != ip || slot.addr.Port < peerPortBase || slot.addr.Port >= peerPortBase+4 {
+		t.Fatalf("unexpected address %v", slot.addr)
+	}
+	if registry.slot(slot.addr) != slot {
+		t.Fatal("acquired slot not registered")
+	}
+	if _, writer := registry.lookup(slot.addr); writer != nil {
+		t.Fatal("lookup returned a writer before the data channel opened")
+	}
+
+	buffer := &bytes.Buffer{}
+	slot.setWriter(buffer)
+	if found, writer := registry.lookup(slot.addr); found != slot || writer != buffer {
+		t.Fatal("lookup didn't return the peer's writer")
+	}
+
+	slot.release()
+	if registry.slot(slot.addr) != nil {
+		t.Fatal("released slot still registered")
+	}
+	if found, writer := registry.lookup(slot.addr); found != nil || writer != nil {
+		t.Fatal("lookup returned a released peer")
+	}
+	if slot.retain() {
+		t.Fatal("retained a released slot")
+	}
+}
+
+func TestRegistryLookupOtherIP(t *testing.T) {
+	registry := &connectionRegistry{free: newIndexPool(1)}
+	slot, _ := registry.acquire([4]byte{10, 0, 0, 1})
+	slot.setWriter(&bytes.Buffer{})
+	// packets the engine still sends to the previous owner of the slot
+	stale := engineAddr{IP: [4]byte{10, 0, 0, 2}, Port: slot.addr.Port}
+	if found, writer := registry.lookup(stale); found != nil || writer != nil {
+		t.Fatal("lookup matched another IP on the same port")
+	}
+	for _, port := range []uint16{0, peerPortBase - 1, peerPortBase + 256, 65535} {
+		if registry.slot(engineAddr{IP: slot.addr.IP, Port: port}) != nil {
+			t.Fatalf("port %d matched a slot", port)
+		}
+	}
+}
+
+func TestRegistryReleaseByAllHolders(t *testing.T) {
+	registry := &connectionRegistry{free: newIndexPool(1)}
+	slot, _ := registry.acquire([4]byte{10, 0, 0, 1})
+	if !slot.retain() {
+		t.Fatal("retain failed on a registered slot")
+	}
+
+	slot.release()
+	if registry.slot(slot.addr) != slot {
+		t.Fatal("slot unregistered while the read loop still holds it")
+	}
+	if _, ok := registry.acquire([4]byte{10, 0, 0, 2}); ok {
+		t.Fatal("index returned to the pool while still held")
+	}
+
+	slot.release()
+	if registry.slot(slot.addr) != nil {
+		t.Fatal("slot registered after both holders released it")
+	}
+	next, ok := registry.acquire([4]byte{10, 0, 0, 2})
+	if !ok {
+		t.Fatal("index didn't return to the pool")
+	}
+	if next.addr.Port != slot.addr.Port || registry.slot(slot.addr) != nil {
+		t.Fatal("the previous owner's address matches the new peer")
+	}
+}
+
+func TestRegistryExhausted(t *testing.T) {
+	registry := &connectionRegistry{free: newIndexPool(2)}
+	first, _ := registry.acquire([4]byte{10, 0, 0, 1})
+	if _, ok := registry.acquire([4]byte{10, 0, 0, 2}); !ok {
+		t.Fatal("acquire failed with a free slot")
+	}
+	if slot, ok := registry.acquire([4]byte{10, 0, 0, 3}); ok || slot != nil {
+		t.Fatal("acquire succeeded with all slots in use")
+	}
+	first.release()
+	if _, ok := registry.acquire([4]byte{10, 0, 0, 3}); !ok {
+		t.Fatal("acquire failed after a slot was released")
+	}
+}
+
+// TestRegistryReleaseRace races the last release against a late retain, like a peer disconnecting while its data
+// channel opens. Run it with -race.
+func TestRegistryReleaseRace(t *testing.T) {
+	registry := &connectionRegistry{free: newIndexPool(1)}
+	for i := 0; i < 1000; i++ {
+		slot, ok := registry.acquire([4]byte{10, 0, 0, byte(i)})
+		if !ok {
+			t.Fatalf("iteration %d: the index wasn't returned to the pool", i)
+		}
+		slot.setWriter(&bytes.Buffer{})
+
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			slot.release()
+		}()
+		go func() {
+			defer wg.Done()
+			if slot.retain() {
+				slot.release()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			registry.lookup(slot.addr)
+		}()
+		wg.Wait()
+
+		if registry.slot(slot.addr) != nil || slot.writer.Load() != nil {
+			t.Fatalf("iteration %d: slot still registered after every holder released it", i)
+		}
+		// the index is back in the pool exactly once
+		index, err := registry.free.TryGet()
+		if err != nil {
+			t.Fatalf("iteration %d: the index wasn't returned to the pool", i)
+		}
+		if _, err := registry.free.TryGet(); err == nil {
+			t.Fatalf("iteration %d: the index was returned twice", i)
+		}
+		registry.free.TryPut(index)
+	}
+}
+
+func TestRegistryEvictsPreviousConnectionOfSession(t *testing.T) {
+	registry := &connectionRegistry{free: newIndexPool(4)}
+	ip := [4]byte{10, 0, 0, 1}
+	first, _ := registry.acquire(ip)
+	other, _ := registry.acquire([4]byte{10, 0, 0, 2})
+	second, _ := registry.acquire(ip)
+
+	select {
+	case <-first.evicted:
+	default:
+		t.Fatal("previous connection of the session wasn't evicted")
+	}
+	select {
+	case <-other.evicted:
+		t.Fatal("connection of another session was evicted")
+	case <-second.evicted:
+		t.Fatal("new connection was evicted")
+	default:
+	}
+}
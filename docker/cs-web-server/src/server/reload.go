@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// restartRequiredSettings are prefixes of settings that can't be changed on a running server.
+// Reloads keep their current values.
+var restartRequiredSettings = []string{
+	"engine.",
+	"libraries.",
+	"http.",
+	"tls.",
+	"network.",
+	"features.",
+	"server.user",
+	"watchdog.timeout",
+	"log.file",
+}
+
+var reloadLock sync.Mutex
+
+type configReload struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// handleReloadSignals reloads the configuration on SIGHUP
+func handleReloadSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		reload, err := reloadConfig()
+		if err != nil {
+			log.Errorf("Failed to reload configuration: %v", err)
+			continue
+		}
+		log.Infof("Configuration reloaded, applied: %v, restart required: %v", reload.Applied, reload.RestartRequired)
+	}
+}
+
+// reloadConfig re-reads the configuration and applies every changed setting that doesn't need a restart
+func reloadConfig() (configReload, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	reload := configReload{Applied: []string{}, RestartRequired: []string{}}
+	cfg, err := readConfig()
+	if err != nil {
+		return reload, err
+	}
+
+	current := appConfig()
+	for _, setting := range changedSettings(reflect.ValueOf(current).Elem(), reflect.ValueOf(cfg).Elem(), "") {
+		if requiresRestart(setting) {
+			reload.RestartRequired = append(reload.RestartRequired, setting)
+		} else {
+			reload.Applied = append(reload.Applied, setting)
+		}
+	}
+
+	cfg.Engine = current.Engine
+	cfg.Libraries = current.Libraries
+	cfg.HTTP = current.HTTP
+	cfg.TLS = current.TLS
+	cfg.Network = current.Network
+	cfg.Features = current.Features
+	cfg.Server.User = current.Server.User
+	cfg.Watchdog.Timeout = current.Watchdog.Timeout
+	cfg.Log.File = current.Log.File
+	if err := applyConfig(cfg); err != nil {
+		return reload, err
+	}
+
+	if slices.Contains(reload.Applied, "server.restart_time") {
+		select {
+		case restartScheduleChanged <- struct{}{}:
+		default:
+		}
+	}
+	return reload, nil
+}
+
+// requiresRestart reports whether a setting only takes effect after a restart
+func requiresRestart(setting string) bool {
+	for _, prefix := range restartRequiredSettings {
+		if strings.HasPrefix(setting, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedSettings lists the yaml paths of the fields that differ between two configs
+func changedSettings(previous, next reflect.Value, prefix string) []string {
+	var changed []string
+	for i := 0; i < previous.NumField(); i++ {
+		name := strings.Split(previous.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if previous.Field(i).Kind() == reflect.Struct {
+			changed = append(changed, changedSettings(previous.Field(i), next.Field(i), name)...)
+			continue
+		}
+		if !reflect.DeepEqual(previous.Field(i).Interface(), next.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// configReloadHandler reloads the configuration and reports what was applied
+func configReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reload, err := reloadConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, reload)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// replaySettle is how long the engine may keep running after the last replayed packet, so a crash it causes is
+// reported before the server shuts down
+const replaySettle = 2 * time.Second
+
+// replayFile returns the capture of "--replay <file>" and the arguments without it, the engine parses the rest
+func replayFile(args []string) (string, []string, bool) {
+	i := slices.Index(args, "--replay")
+	if i < 0 || i+1 >= len(args) {
+		return "", args, false
+	}
+	return args[i+1], slices.Delete(slices.Clone(args), i, i+2), true
+}
+
+// loadCapture reads the packets of a capture downloaded from /v1/debug/capture
+func loadCapture(path string) ([]capturedPacket, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	packets := []capturedPacket{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 4*messageSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		packet := capturedPacket{}
+		if err := json.Unmarshal(scanner.Bytes(), &packet); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		packets = append(packets, packet)
+	}
+	return packets, scanner.Err()
+}
+
+// replayWriter counts the engine's replies to the replayed peer
+type replayWriter struct {
+	packets atomic.Int64
+}
+
+func (w *replayWriter) Write(p []byte) (int, error) {
+	w.packets.Add(1)
+	return len(p), nil
+}
+
+// runReplay feeds the player's packets of a capture to the engine with their original timing, as a new peer,
+// then shuts the server down. An engine crash during the replay exits with its code, so replays can run in CI.
+func runReplay(packets []capturedPacket) {
+	for lastEngineHeartbeat().IsZero() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	slot, ok := peers.acquire(sessionIP(""))
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Replay failed: all peer slots are in use")
+		shutdown(1)
+		return
+	}
+	replies := &replayWriter{}
+	slot.setWriter(replies)
+
+	var sent, captured int
+	var first time.Time
+	started := time.Now()
+	for _, packet := range packets {
+		if packet.Direction != "in" {
+			captured++
+			continue
+		}
+		if first.IsZero() {
+			first = packet.Time
+		}
+		time.Sleep(time.Until(started.Add(packet.Time.Sub(first))))
+		net.PushPacket(enginePacket{Addr: slot.addr, Data: packet.Data})
+		sent++
+	}
+	time.Sleep(replaySettle)
+	slot.release()
+
+	fmt.Fprintf(os.Stderr, "Replayed %d packets in %v, the engine sent %d packets (%d in the capture)\n",
+		sent, time.Since(started).Round(time.Millisecond), replies.packets.Load(), captured)
+	shutdown(0)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzLoadCapture(f *testing.F) {
+	f.Add([]byte(`{"time":"2025-01-01T00:00:00Z","direction":"in","data":"/////2dldGNoYWxsZW5nZQ=="}` + "\n\n" + `{"direction":"out","data":null}`))
+	f.Add([]byte(`{"data":"not base64"}`))
+	f.Add([]byte("\n\n\n"))
+	f.Add([]byte(`[]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "capture.jsonl")
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		packets, err := loadCapture(path)
+		if err != nil {
+			return
+		}
+		// a loaded capture written back loads the same packets
+		lines := []string{}
+		for _, packet := range packets {
+			line, err := json.Marshal(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(line) > 4*messageSize {
+				// escaping grew the line past what a capture line may be
+				return
+			}
+			lines = append(lines, string(line))
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		reloaded, err := loadCapture(path)
+		if err != nil {
+			t.Fatalf("rewritten capture failed to load: %v", err)
+		}
+		if len(reloaded) != len(packets) {
+			t.Fatalf("loaded %d packets, rewritten %d", len(packets), len(reloaded))
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	restartNow   = "now"
+	restartEmpty = "empty"
+
+	// restartExitCode is non-zero so "on-failure" restart policies restart the container too
+	restartExitCode = 75
+
+	restartPollInterval = 5 * time.Second
+	restartMessage      = "Server is restarting"
+)
+
+// restartState tracks a restart waiting for the server to empty
+type restartState struct {
+	sync.Mutex
+	pending     bool
+	drain       bool
+	requestedAt time.Time
+	cancel      chan struct{}
+}
+
+var (
+	restarts = &restartState{}
+
+	// restartScheduleChanged wakes the restart scheduler up after a config reload
+	restartScheduleChanged = make(chan struct{}, 1)
+)
+
+type restartRequest struct {
+	When  string `json:"when"`
+	Drain bool   `json:"drain"`
+}
+
+type restartStatus struct {
+	Pending     bool       `json:"pending"`
+	Drain       bool       `json:"drain,omitempty"`
+	RequestedAt *time.Time `json:"requested_at,omitempty"`
+	Peers       int        `json:"peers"`
+}
+
+// runScheduledRestarts requests a restart once the server is empty every day at the configured time
+func runScheduledRestarts() {
+	for {
+		var (
+			timer *time.Timer
+			wait  <-chan time.Time
+		)
+		if value := appConfig().Server.RestartTime; value != "" {
+			at, _ := time.Parse("15:04", value) // validated on load
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			timer = time.NewTimer(time.Until(next))
+			wait = timer.C
+		}
+
+		select {
+		case <-wait:
+			log.Infof("Scheduled restart requested")
+			restarts.request(restartEmpty, false)
+		case <-restartScheduleChanged:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// request restarts right away or once no peers are connected.
+// With drain set, maintenance mode is enabled so the server actually empties.
+func (s *restartState) request(when string, drain bool) {
+	if when == restartNow {
+		restartServer()
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if drain {
+		maintenance.set(true, restartMessage)
+	}
+	if s.pending {
+		s.drain = s.drain || drain
+		return
+	}
+	s.pending = true
+	s.drain = drain
+	s.requestedAt = time.Now()
+	s.cancel = make(chan struct{})
+	go s.waitForEmpty(s.cancel)
+}
+
+// abort cancels a pending restart, leaving maintenance mode if the restart enabled it
+func (s *restartState) abort() {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.pending {
+		return
+	}
+	if s.drain {
+		maintenance.set(false, "")
+	}
+	close(s.cancel)
+	s.pending = false
+	s.drain = false
+}
+
+func (s *restartState) waitForEmpty(cancel chan struct{}) {
+	ticker := time.NewTicker(restartPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cancel:
+			return
+		case <-ticker.C:
+			if activePeerCount() == 0 {
+				restartServer()
+				return
+			}
+		}
+	}
+}
+
+func (s *restartState) status() restartStatus {
+	peers := activePeerCount()
+
+	s.Lock()
+	defer s.Unlock()
+
+	status := restartStatus{
+		Pending: s.pending,
+		Peers:   peers,
+	}
+	if s.pending {
+		requestedAt := s.requestedAt
+		status.Drain = s.drain
+		status.RequestedAt = &requestedAt
+	}
+	return status
+}
+
+// restartServer goes through the graceful shutdown path and exits with restartExitCode
+func restartServer() {
+	log.Infof("Restarting server")
+	shutdown(restartExitCode)
+}
+
+// restartHandler reports (GET), requests (POST) or cancels (DELETE) a server restart
+func restartHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		req := restartRequest{When: restartNow}
+		if err := decodeJSON(w, r, &req); err != nil && err != io.EOF {
+			writeBodyError(w, err)
+			return
+		}
+		if req.When != restartNow && req.When != restartEmpty {
+			http.Error(w, fmt.Sprintf("when must be %q or %q", restartNow, restartEmpty), http.StatusBadRequest)
+			return
+		}
+		if req.When == restartNow {
+			writeJSON(w, http.StatusAccepted, restartStatus{Pending: true})
+			go restarts.request(restartNow, false)
+			return
+		}
+		restarts.request(req.When, req.Drain)
+	case http.MethodDelete:
+		restarts.abort()
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, restarts.status())
+}
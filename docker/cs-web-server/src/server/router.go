@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+// middleware wraps a handler with cross-cutting behaviour
+type middleware func(http.Handler) http.Handler
+
+// groupMiddleware is applied to every route of a group, in order from outermost to innermost
+var groupMiddleware = map[string][]middleware{
+	routePublic: nil,
+	routeHealth: nil,
+	// CORS comes first so preflight requests are answered without the admin token
+	routeAdmin:   {cors, requireAdmin},
+	routeMetrics: {requireMetricsAuth},
+}
+
+// router registers routes on a mux, skipping the route groups its listener doesn't serve
+type router struct {
+	mux    *http.ServeMux
+	groups []string
+}
+
+// groupEnabled reports whether the feature serving a route group is switched on
+func groupEnabled(group string) bool {
+	switch group {
+	case routeAdmin:
+		return appConfig().Features.Admin
+	case routeMetrics:
+		return appConfig().Features.Metrics
+	}
+	return true
+}
+
+// handle registers handler for pattern in a route group, wrapped in the group's middleware
+func (rt *router) handle(group, pattern string, handler http.HandlerFunc) {
+	if !slices.Contains(rt.groups, group) || !groupEnabled(group) {
+		return
+	}
+	rt.mux.Handle(pattern, chain(handler, groupMiddleware[group]...))
+}
+
+// chain wraps handler so the first middleware runs first
+func chain(handler http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// newRouter returns the handler of a listener serving the given route groups.
+// New endpoints only need to be added here, in the group deciding their middleware.
+func newRouter(groups []string) http.Handler {
+	rt := &router{mux: http.NewServeMux(), groups: groups}
+
+	rt.handle(routePublic, "/websocket", websocketHandler)
+	rt.handle(routePublic, "/config", configHandler)
+	rt.handle(routePublic, "/v1/ice", iceHandler)
+	rt.handle(routePublic, archivesPrefix, archiveHandler)
+	rt.handle(routePublic, fastDLPrefix, fastDLHandler)
+	rt.handle(routePublic, "/", assetHandler)
+
+	rt.handle(routeHealth, "/healthz", healthzHandler)
+	rt.handle(routeHealth, "/readyz", readyzHandler)
+	rt.handle(routeHealth, "/livez", livezHandler)
+
+	rt.handle(routeAdmin, "/v1/maintenance", maintenanceHandler)
+	rt.handle(routeAdmin, "/v1/restart", restartHandler)
+	rt.handle(routeAdmin, "/v1/config/reload", configReloadHandler)
+	rt.handle(routeAdmin, "/v1/logs", logsHandler)
+	rt.handle(routeAdmin, "/v1/logs/download", logsDownloadHandler)
+	rt.handle(routeAdmin, "/v1/loglevel", logLevelHandler)
+	rt.handle(routeAdmin, "/v1/version", versionHandler)
+	rt.handle(routeAdmin, "/v1/status", statusHandler)
+	registerDebugRoutes(rt)
+
+	rt.handle(routeMetrics, "/metrics", metricsHandler)
+
+	return chain(rt.mux, accessLogging, securityHeaders, writeTimeouts)
+}
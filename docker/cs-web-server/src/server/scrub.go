@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	gonet "net"
+	"regexp"
+	"strings"
+)
+
+const scrubbedMask = "[REDACTED]"
+
+// secretPatterns match credentials that must never reach the log file
+var secretPatterns = []*regexp.Regexp{
+	// JSON web tokens
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	// bcrypt and argon2 password hashes
+	regexp.MustCompile(`\$2[aby]?\$\d{2}\$[./A-Za-z0-9]{53}`),
+	regexp.MustCompile(`\$argon2(?:id|i|d)\$\S+`),
+}
+
+// bearerToken matches the credentials of an Authorization header
+var bearerToken = regexp.MustCompile(`(?i)(bearer\s+)\S+`)
+
+// addressCandidate matches whole words containing a dot or colon, only words that are an address as a whole
+// are masked, so versions like 1.2.3.4.5, timestamps and names like pkg::func aren't mistaken for one
+var addressCandidate = regexp.MustCompile(`[0-9A-Za-z_]*[:.][0-9A-Za-z_:.]*`)
+
+// scrubLogLine masks secrets in a line before it's persisted, and with log.scrub_ips reduces
+// client addresses to their /24 (IPv4) or /48 (IPv6) network, so the logs are safe to share
+func scrubLogLine(line []byte) []byte {
+	for _, pattern := range secretPatterns {
+		line = pattern.ReplaceAll(line, []byte(scrubbedMask))
+	}
+	line = bearerToken.ReplaceAll(line, []byte("${1}"+scrubbedMask))
+	for _, secret := range []string{appConfig().Server.AdminToken, appConfig().Signaling.SessionSecret, appConfig().ICE.TURNSecret} {
+		if secret != "" {
+			line = bytes.ReplaceAll(line, []byte(secret), []byte(scrubbedMask))
+		}
+	}
+	if appConfig().Log.ScrubIPs {
+		line = addressCandidate.ReplaceAllFunc(line, maskAddress)
+	}
+	return line
+}
+
+// maskAddress replaces an IP address, optionally followed by a port or trailing punctuation, with its network
+func maskAddress(word []byte) []byte {
+	text := string(word)
+	for _, candidate := range []string{text, strings.TrimRight(text, ".:")} {
+		host, port := candidate, ""
+		if h, p, ok := strings.Cut(candidate, ":"); ok && !strings.Contains(p, ":") && isPort(p) {
+			host, port = h, ":"+p
+		}
+		if network, ok := maskIP(host); ok {
+			return []byte(network + port + text[len(candidate):])
+		}
+	}
+	return word
+}
+
+// maskIP returns the network of a client address, loopback and unspecified addresses aren't masked
+func maskIP(host string) (string, bool) {
+	ip := gonet.ParseIP(host)
+	switch {
+	case ip == nil || ip.IsLoopback() || ip.IsUnspecified():
+		return "", false
+	case ip.To4() != nil:
+		return ip.Mask(gonet.CIDRMask(24, 32)).String() + "/24", true
+	default:
+		return ip.Mask(gonet.CIDRMask(48, 128)).String() + "/48", true
+	}
+}
+
+func isPort(text string) bool {
+	if text == "" || len(text) > 5 {
+		return false
+	}
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
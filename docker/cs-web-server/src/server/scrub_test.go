@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestScrubLogLineMasksAddresses(t *testing.T) {
+	cfg := useTestConfig(t)
+	cfg.Log.ScrubIPs = true
+
+	tests := []struct {
+		line, want string
+	}{
+		{"client 203.0.113.7 connected", "client 203.0.113.0/24 connected"},
+		{"packet from 203.0.113.7:27005", "packet from 203.0.113.0/24:27005"},
+		{"dropped 203.0.113.7.", "dropped 203.0.113.0/24."},
+		{"peer 203.0.113.7: timeout", "peer 203.0.113.0/24: timeout"},
+		{"candidate 2001:db8:1234:5678::1 udp", "candidate 2001:db8:1234::/48 udp"},
+		{"candidate [2001:db8:1234:5678::1]:3478", "candidate [2001:db8:1234::/48]:3478"},
+		{"listening on 127.0.0.1:27015 and ::1", "listening on 127.0.0.1:27015 and ::1"},
+		// not addresses
+		{"Xash3D FWGS 0.21.1.2.3 build", "Xash3D FWGS 0.21.1.2.3 build"},
+		{"go1.22.3 linux/amd64", "go1.22.3 linux/amd64"},
+		{"2026-10-15T12:34:56Z map changed at 12:34:56.789", "2026-10-15T12:34:56Z map changed at 12:34:56.789"},
+		{"panic in Class::dead", "panic in Class::dead"},
+		{"ratio 1:2 at cafe:beef", "ratio 1:2 at cafe:beef"},
+	}
+	for _, test := range tests {
+		if got := string(scrubLogLine([]byte(test.line))); got != test.want {
+			t.Errorf("scrubLogLine(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// secretSettings are the settings that can be read from files, keyed by their environment variable
+func secretSettings(cfg *Config) map[string]*string {
+	return map[string]*string{
+		"ADMIN_TOKEN":              &cfg.Server.AdminToken,
+		"ADMIN_TOKEN_HASH":         &cfg.Server.AdminTokenHash,
+		"SIGNALING_SESSION_SECRET": &cfg.Signaling.SessionSecret,
+		"METRICS_PASSWORD":         &cfg.Metrics.Password,
+		"TURN_SECRET":              &cfg.ICE.TURNSecret,
+	}
+}
+
+// loadSecretFiles fills secrets from files so they stay out of the environment and docker inspect.
+// A variable set directly wins, then <NAME>_FILE, then a file named <NAME> in SECRETS_DIR (like /run/secrets).
+// Both override the config file, and reloads read the files again so secrets can be rotated.
+func loadSecretFiles(cfg *Config) error {
+	dir := os.Getenv("SECRETS_DIR")
+	for env, value := range secretSettings(cfg) {
+		if os.Getenv(env) != "" {
+			continue
+		}
+		if path := os.Getenv(env + "_FILE"); path != "" {
+			secret, err := readSecretFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s_FILE: %w", env, err)
+			}
+			*value = secret
+			continue
+		}
+		if dir == "" {
+			continue
+		}
+		secret, err := readSecretFile(filepath.Join(dir, env))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s from SECRETS_DIR: %w", env, err)
+		}
+		*value = secret
+	}
+	return nil
+}
+
+// readSecretFile returns the content of a secret file without the trailing newline editors and echo add
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// generatedSessionSecret signs session tokens when signaling.session_secret is empty, tokens then end with the process
+var generatedSessionSecret = sync.OnceValue(func() []byte {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+})
+
+func sessionSecret() []byte {
+	if secret := appConfig().Signaling.SessionSecret; secret != "" {
+		return []byte(secret)
+	}
+	return generatedSessionSecret()
+}
+
+func signSession(payload string) string {
+	mac := hmac.New(sha256.New, sessionSecret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issueSessionToken returns a token binding the engine IP to the player: "<ip hex>.<expiry unix>.<signature>"
+func issueSessionToken(ip [4]byte) string {
+	payload := fmt.Sprintf("%s.%d", hex.EncodeToString(ip[:]), time.Now().Add(appConfig().Signaling.SessionTTL).Unix())
+	return payload + "." + signSession(payload)
+}
+
+// parseSessionToken returns the engine IP of a valid, unexpired token
+func parseSessionToken(token string) ([4]byte, bool) {
+	var ip [4]byte
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(signSession(token[:i]))) {
+		return ip, false
+	}
+	ipHex, expiry, ok := strings.Cut(token[:i], ".")
+	if !ok {
+		return ip, false
+	}
+	expires, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return ip, false
+	}
+	if len(ipHex) != hex.EncodedLen(len(ip)) {
+		return ip, false
+	}
+	if _, err := hex.Decode(ip[:], []byte(ipHex)); err != nil {
+		return ip, false
+	}
+	return ip, true
+}
+
+// sessionIP returns the engine IP of the player's session token, or a random one for a new session.
+// The engine identifies players by IP, so a stable IP keeps its bans and stats attached to the same player.
+func sessionIP(token string) [4]byte {
+	if ip, ok := parseSessionToken(token); ok {
+		return ip
+	}
+	var ip [4]byte
+	rand.Read(ip[:])
+	return ip
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSessionToken(t *testing.T) {
+	cfg := useTestConfig(t)
+	ip := [4]byte{10, 1, 2, 3}
+	valid := issueSessionToken(ip)
+	expiry := time.Now().Add(time.Hour).Unix()
+	signed := func(payload string) string {
+		return payload + "." + signSession(payload)
+	}
+	cfg.Signaling.SessionSecret = "other-secret"
+	otherSecret := issueSessionToken(ip)
+	cfg.Signaling.SessionSecret = "test-secret"
+	tamperedSignature := []byte(valid)
+	tamperedSignature[len(tamperedSignature)-1] ^= 1
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", valid, true},
+		{"expired", signed(fmt.Sprintf("0a010203.%d", time.Now().Add(-time.Minute).Unix())), false},
+		{"tampered ip", "0a010204" + valid[len("0a010203"):], false},
+		{"tampered signature", string(tamperedSignature), false},
+		{"other secret", otherSecret, false},
+		{"empty", "", false},
+		{"no dots", "0a010203", false},
+		{"unsigned", fmt.Sprintf("0a010203.%d", expiry), false},
+		{"missing expiry", signed("0a010203"), false},
+		{"bad expiry", signed("0a010203.soon"), false},
+		{"bad hex", signed(fmt.Sprintf("0a01020z.%d", expiry)), false},
+		{"short ip", signed(fmt.Sprintf("0a0102.%d", expiry)), false},
+		{"ipv6 length ip", signed(fmt.Sprintf("20010db8000000000000000000000001.%d", expiry)), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, ok := parseSessionToken(test.token)
+			if ok != test.ok {
+				t.Fatalf("parseSessionToken(%q) = %v, want %v", test.token, ok, test.ok)
+			}
+			if ok && parsed != ip {
+				t.Fatalf("parsed %v, issued for %v", parsed, ip)
+			}
+		})
+	}
+}
+
+func FuzzParseSessionToken(f *testing.F) {
+	f.Add("0a0b0c0d.4102444800.signature")
+	f.Add("..")
+	f.Add("0a0b0c0d")
+	f.Add("zz.1.")
+	f.Fuzz(func(t *testing.T, token string) {
+		useTestConfig(t)
+		if _, ok := parseSessionToken(token); !ok {
+			return
+		}
+		// only tokens the server signed are accepted
+		payload := token[:strings.LastIndexByte(token, '.')]
+		if token != payload+"."+signSession(payload) {
+			t.Fatalf("accepted unsigned token %q", token)
+		}
+	})
+}
+
+func FuzzSessionTokenRoundTrip(f *testing.F) {
+	f.Add([]byte{10, 0, 0, 1})
+	f.Add([]byte{0, 0, 0, 0})
+	f.Add([]byte{255, 255, 255, 255})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		useTestConfig(t)
+		var ip [4]byte
+		copy(ip[:], data)
+		parsed, ok := parseSessionToken(issueSessionToken(ip))
+		if !ok || parsed != ip {
+			t.Fatalf("issued token for %v parsed as %v, %v", ip, parsed, ok)
+		}
+	})
+}
@@ -4,45 +4,49 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/websocket"
-	"github.com/jinzhu/configor"
 	"github.com/pion/ice/v4"
 	"github.com/pion/interceptor"
 	"github.com/pion/logging"
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v4"
-	"github.com/yohimik/goxash3d-fwgs/pkg"
 	"io"
-	"math/rand"
+	"maps"
 	"net/http"
 	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var net = NewSFUNet()
 
 type SFUNet struct {
-	*goxash3d_fwgs.BaseNet
+	*engineNet
 }
 
 func NewSFUNet() *SFUNet {
-	return &SFUNet{
-		BaseNet: goxash3d_fwgs.NewBaseNet(goxash3d_fwgs.BaseNetOptions{
-			HostName: "webxash",
-			HostID:   3000,
-		}),
+	return &SFUNet{engineNet: newEngineNet()}
+}
+
+// RecvFrom is polled by the engine every frame, so it doubles as the engine heartbeat.
+// The engine reads until the queue is empty, so the empty polls approximate the frames.
+func (n *SFUNet) RecvFrom() *enginePacket {
+	now := time.Now().UnixNano()
+	engineHeartbeat.Store(now)
+	packet := n.engineNet.RecvFrom()
+	if packet == nil {
+		enginePollTimes.poll(now)
 	}
+	return packet
 }
 
-func (n *SFUNet) SendTo(fd int, packet goxash3d_fwgs.Packet, flags int) int {
-	conn := connections[packet.Addr.IP[0]]
+func (n *SFUNet) SendTo(fd int, packet enginePacket, flags int) int {
+	slot, conn := peers.lookup(packet.Addr)
 	if conn == nil {
 		return -1
 	}
+	slot.captured("out", packet.Data)
 	nn, err := conn.Write(packet.Data)
 	if err != nil {
 		return -1
@@ -50,7 +54,7 @@ func (n *SFUNet) SendTo(fd int, packet goxash3d_fwgs.Packet, flags int) int {
 	return nn
 }
 
-func (n *SFUNet) SendToBatch(fd int, packets []goxash3d_fwgs.Packet, flags int) int {
+func (n *SFUNet) SendToBatch(fd int, packets []enginePacket, flags int) int {
 	sum := 0
 	for _, packet := range packets {
 		nn := n.SendTo(fd, packet, flags)
@@ -62,23 +66,30 @@ func (n *SFUNet) SendToBatch(fd int, packets []goxash3d_fwgs.Packet, flags int)
 	return sum
 }
 
-var pool = goxash3d_fwgs.NewBytesPool(256)
-var connections = make([]io.Writer, 256)
-
 var (
-	addr     = ":27016"
 	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
+		CheckOrigin: checkWebSocketOrigin,
 	}
 
 	api *webrtc.API
 
 	// lock for peerConnections and trackLocals
 	listLock        sync.RWMutex
-	peerConnections []*peerConnectionState
+	peerConnections = map[uint64]*peerConnectionState{}
 	trackLocals     map[string]*webrtc.TrackLocalStaticRTP
+	nextPeerID      atomic.Uint64
+
+	log logging.LeveledLogger = logging.NewDefaultLeveledLoggerForScope(serverLogScope, logLevelValues[defaultLogLevel()], os.Stderr)
+)
+
+// sessionReplacedMessage is shown to a connection replaced by a newer one of its session
+const sessionReplacedMessage = "Connected again in another window"
 
-	log = logging.NewDefaultLoggerFactory().NewLogger("sfu-ws")
+const (
+	// renegotiationRetryDelay is how long a peer waits before retrying a failed renegotiation
+	renegotiationRetryDelay = 3 * time.Second
+	// renegotiationAttempts is how often a renegotiation is tried before waiting for the next change of tracks
+	renegotiationAttempts = 5
 )
 
 type websocketMessage struct {
@@ -86,13 +97,167 @@ type websocketMessage struct {
 	Data  json.RawMessage `json:"data"`
 }
 
+// peerConnectionState is a connected peer. Its renegotiations run one at a time on its own worker.
 type peerConnectionState struct {
+	id             uint64
 	peerConnection *webrtc.PeerConnection
 	websocket      *threadSafeWriter
-	signalsCount   int
+	// renegotiate wakes the worker, a request that is already queued absorbs further ones
+	renegotiate chan struct{}
+	// afterAnswer is set when a renegotiation was requested while an offer awaited its answer
+	afterAnswer atomic.Bool
+}
+
+func newPeerConnectionState(peerConnection *webrtc.PeerConnection, websocket *threadSafeWriter) *peerConnectionState {
+	return &peerConnectionState{
+		id:             nextPeerID.Add(1),
+		peerConnection: peerConnection,
+		websocket:      websocket,
+		renegotiate:    make(chan struct{}, 1),
+	}
+}
+
+// requestRenegotiation asks the peer's worker to bring its tracks up to date
+func (p *peerConnectionState) requestRenegotiation() {
+	select {
+	case p.renegotiate <- struct{}{}:
+	default:
+	}
 }
 
-const DefaultSignalsCount = 5
+// runRenegotiations handles the renegotiation requests of the peer until done is closed or its PeerConnection is.
+// Requests are debounced by signaling.renegotiation_delay and coalesced into one offer.
+// A failed renegotiation is retried at most renegotiationAttempts times.
+func (p *peerConnectionState) runRenegotiations(done <-chan struct{}) {
+	var retry <-chan time.Time
+	failures := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-p.renegotiate:
+		case <-retry:
+		}
+		retry = nil
+		if p.closed() {
+			return
+		}
+
+		// let a burst of joins and leaves settle into a single offer, the first offer of a peer isn't held back
+		if delay := appConfig().Signaling.RenegotiationDelay; delay > 0 && p.peerConnection.CurrentRemoteDescription() != nil {
+			select {
+			case <-done:
+				return
+			case <-time.After(delay):
+			}
+			// requests made while waiting are covered by this renegotiation
+			select {
+			case <-p.renegotiate:
+			default:
+			}
+		}
+
+		offered, err := p.sync()
+		if err != nil {
+			if p.closed() {
+				return
+			}
+			failures++
+			if failures >= renegotiationAttempts {
+				log.Errorf("Failed to renegotiate peer %d %d times, giving up until its tracks change: %v", p.id, failures, err)
+				failures = 0
+				continue
+			}
+			log.Warnf("Failed to renegotiate peer %d, retrying in %v: %v", p.id, renegotiationRetryDelay, err)
+			retry = time.After(renegotiationRetryDelay)
+			continue
+		}
+		failures = 0
+		if offered {
+			dispatchKeyFrame()
+		}
+	}
+}
+
+// closed reports whether the peer's PeerConnection can't be renegotiated anymore
+func (p *peerConnectionState) closed() bool {
+	switch p.peerConnection.ConnectionState() {
+	case webrtc.PeerConnectionStateClosed, webrtc.PeerConnectionStateFailed:
+		return true
+	}
+	return false
+}
+
+// sync updates the peer's senders to the current tracks and sends it an offer.
+// While a previous offer awaits its answer nothing is sent, the answer triggers the renegotiation instead.
+func (p *peerConnectionState) sync() (bool, error) {
+	if p.peerConnection.SignalingState() != webrtc.SignalingStateStable {
+		p.afterAnswer.Store(true)
+		// the answer may have arrived before the flag was set
+		if p.peerConnection.SignalingState() == webrtc.SignalingStateStable && p.afterAnswer.Swap(false) {
+			p.requestRenegotiation()
+		}
+		return false, nil
+	}
+
+	listLock.RLock()
+	tracks := maps.Clone(trackLocals)
+	listLock.RUnlock()
+
+	// map of sender we already are seanding, so we don't double send
+	existingSenders := map[string]bool{}
+
+	for _, sender := range p.peerConnection.GetSenders() {
+		if sender.Track() == nil {
+			continue
+		}
+
+		existingSenders[sender.Track().ID()] = true
+
+		// If we have a RTPSender that doesn't map to a existing track remove and signal
+		if _, ok := tracks[sender.Track().ID()]; !ok {
+			if err := p.peerConnection.RemoveTrack(sender); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	// Don't receive videos we are sending, make sure we don't have loopback
+	for _, receiver := range p.peerConnection.GetReceivers() {
+		if receiver.Track() == nil {
+			continue
+		}
+
+		existingSenders[receiver.Track().ID()] = true
+	}
+
+	// Add all track we aren't sending yet to the PeerConnection
+	for trackID, track := range tracks {
+		if _, ok := existingSenders[trackID]; !ok {
+			if _, err := p.peerConnection.AddTrack(track); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	offer, err := p.peerConnection.CreateOffer(nil)
+	if err != nil {
+		return false, err
+	}
+
+	if err = p.peerConnection.SetLocalDescription(offer); err != nil {
+		return false, err
+	}
+
+	return true, p.websocket.WriteJSON("offer", offer)
+}
+
+// answered continues a renegotiation that was held back while the peer's answer was pending
+func (p *peerConnectionState) answered() {
+	if p.afterAnswer.Swap(false) {
+		p.requestRenegotiation()
+	}
+}
 
 // Add to list of tracks and fire renegotation for all PeerConnections.
 func addTrack(t *webrtc.TrackRemote) *webrtc.TrackLocalStaticRTP { // nolint
@@ -110,10 +275,6 @@ func addTrack(t *webrtc.TrackRemote) *webrtc.TrackLocalStaticRTP { // nolint
 
 	trackLocals[t.ID()] = trackLocal
 
-	for _, con := range peerConnections {
-		con.signalsCount = DefaultSignalsCount
-	}
-
 	return trackLocal
 }
 
@@ -125,115 +286,46 @@ func removeTrack(t *webrtc.TrackLocalStaticRTP) {
 		signalPeerConnections()
 	}()
 
-	for _, con := range peerConnections {
-		con.signalsCount = DefaultSignalsCount
-	}
-
 	delete(trackLocals, t.ID())
 }
 
-// signalPeerConnections updates each PeerConnection so that it is getting all the expected media tracks.
-func signalPeerConnections() { // nolint
-	listLock.Lock()
-	defer func() {
-		listLock.Unlock()
-		dispatchKeyFrame()
-	}()
-
-	attemptSync := func() (tryAgain bool) {
-		for i := range peerConnections {
-			if peerConnections[i].signalsCount <= 0 {
-				continue
-			}
-
-			if peerConnections[i].peerConnection.ConnectionState() == webrtc.PeerConnectionStateClosed {
-				peerConnections = append(peerConnections[:i], peerConnections[i+1:]...)
-
-				return true // We modified the slice, start from the beginning
-			}
-
-			// map of sender we already are seanding, so we don't double send
-			existingSenders := map[string]bool{}
-
-			for _, sender := range peerConnections[i].peerConnection.GetSenders() {
-				if sender.Track() == nil {
-					continue
-				}
-
-				existingSenders[sender.Track().ID()] = true
-
-				// If we have a RTPSender that doesn't map to a existing track remove and signal
-				if _, ok := trackLocals[sender.Track().ID()]; !ok {
-					if err := peerConnections[i].peerConnection.RemoveTrack(sender); err != nil {
-						return true
-					}
-				}
-			}
-
-			// Don't receive videos we are sending, make sure we don't have loopback
-			for _, receiver := range peerConnections[i].peerConnection.GetReceivers() {
-				if receiver.Track() == nil {
-					continue
-				}
-
-				existingSenders[receiver.Track().ID()] = true
-			}
-
-			// Add all track we aren't sending yet to the PeerConnection
-			for trackID := range trackLocals {
-				if _, ok := existingSenders[trackID]; !ok {
-					if _, err := peerConnections[i].peerConnection.AddTrack(trackLocals[trackID]); err != nil {
-						return true
-					}
-				}
-			}
+// signalPeerConnections asks every PeerConnection to renegotiate so that it is getting all the expected media tracks.
+func signalPeerConnections() {
+	listLock.RLock()
+	defer listLock.RUnlock()
 
-			offer, err := peerConnections[i].peerConnection.CreateOffer(nil)
-			if err != nil {
-				return true
-			}
-
-			if err = peerConnections[i].peerConnection.SetLocalDescription(offer); err != nil {
-				return true
-			}
-
-			if err = peerConnections[i].websocket.WriteJSON("offer", offer); err != nil {
-				return true
-			}
-		}
-
-		return tryAgain
+	for _, state := range peerConnections {
+		state.requestRenegotiation()
 	}
+}
 
-	for syncAttempt := 0; ; syncAttempt++ {
-		if syncAttempt == 25 {
-			// Release the lock and attempt a sync in 3 seconds. We might be blocking a RemoveTrack or AddTrack
-			go func() {
-				time.Sleep(time.Second * 3)
-				signalPeerConnections()
-			}()
-
-			return
-		}
+// activePeerCount returns the number of PeerConnections that are not closed yet.
+func activePeerCount() int {
+	listLock.RLock()
+	defer listLock.RUnlock()
 
-		if !attemptSync() {
-			break
+	count := 0
+	for _, con := range peerConnections {
+		if con.peerConnection.ConnectionState() != webrtc.PeerConnectionStateClosed {
+			count++
 		}
 	}
+
+	return count
 }
 
 // dispatchKeyFrame sends a keyframe to all PeerConnections, used everytime a new user joins the call.
 func dispatchKeyFrame() {
-	listLock.Lock()
-	defer listLock.Unlock()
+	listLock.RLock()
+	defer listLock.RUnlock()
 
-	for i := range peerConnections {
-		for _, receiver := range peerConnections[i].peerConnection.GetReceivers() {
+	for _, state := range peerConnections {
+		for _, receiver := range state.peerConnection.GetReceivers() {
 			if receiver.Track() == nil {
 				continue
 			}
 
-			_ = peerConnections[i].peerConnection.WriteRTCP([]rtcp.Packet{
+			_ = state.peerConnection.WriteRTCP([]rtcp.Packet{
 				&rtcp.PictureLossIndication{
 					MediaSSRC: uint32(receiver.Track().SSRC()),
 				},
@@ -244,7 +336,10 @@ func dispatchKeyFrame() {
 
 const messageSize = 1024 * 8
 
-func ReadLoop(d io.Reader, ip [4]byte) {
+// ReadLoop pushes the packets of a peer to the engine, holding a reference to its slot until the channel closes
+func ReadLoop(d io.Reader, slot *peerSlot) {
+	defer slot.release()
+	filter := packetFilter{}
 	for {
 		buffer := make([]byte, messageSize)
 		n, err := d.Read(buffer)
@@ -253,11 +348,12 @@ func ReadLoop(d io.Reader, ip [4]byte) {
 
 			return
 		}
-		net.PushPacket(goxash3d_fwgs.Packet{
-			Addr: goxash3d_fwgs.Addr{
-				IP:   ip,
-				Port: 1000,
-			},
+		if !filter.allow(buffer[:n], time.Now()) {
+			continue
+		}
+		slot.captured("in", buffer[:n])
+		net.PushPacket(enginePacket{
+			Addr: slot.addr,
 			Data: buffer[:n],
 		})
 	}
@@ -268,20 +364,40 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 	// Upgrade HTTP request to Websocket
 	unsafeConn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Errorf("Failed to upgrade HTTP to Websocket: ", err)
+		log.Errorf("Failed to upgrade HTTP to Websocket: %v", err)
 
 		return
 	}
 
+	// signaling messages share the request body size limit
+	unsafeConn.SetReadLimit(appConfig().HTTP.MaxBodyBytes)
+
 	c := &threadSafeWriter{unsafeConn, sync.Mutex{}} // nolint
 
 	// When this frame returns close the Websocket
 	defer c.Close() //nolint
 
+	// Refuse new peers while draining for maintenance
+	if message, ok := maintenance.check(); ok {
+		if err := c.WriteJSON("maintenance", map[string]string{"message": message}); err != nil {
+			log.Errorf("Failed to write JSON: %v", err)
+		}
+
+		return
+	}
+
+	// Only clients that answer the challenge get a slot and a PeerConnection
+	session, err := challengeClient(c)
+	if err != nil {
+		log.Warnf("Client failed the challenge: %v", err)
+
+		return
+	}
+
 	// Create new PeerConnection
 	peerConnection, err := api.NewPeerConnection(webrtc.Configuration{})
 	if err != nil {
-		log.Errorf("Failed to creates a PeerConnection: %v", err)
+		log.Errorf("Failed to create a PeerConnection: %v", err)
 
 		return
 	}
@@ -289,8 +405,12 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 	// When this frame returns close the PeerConnection
 	defer peerConnection.Close() //nolint
 
-	// Accept one audio track incoming
-	for _, typ := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio} {
+	// Accept one audio track incoming, without voice the peer only gets the data channel
+	codecTypes := []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio}
+	if !appConfig().Features.Voice {
+		codecTypes = nil
+	}
+	for _, typ := range codecTypes {
 		if _, err := peerConnection.AddTransceiverFromKind(typ, webrtc.RTPTransceiverInit{
 			Direction: webrtc.RTPTransceiverDirectionRecvonly,
 		}); err != nil {
@@ -302,25 +422,42 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 
 	f := false
 	var z uint16 = 0
-	if err != nil {
-		log.Errorf("Failed to creates a data channel: %v", err)
+	slot, ok := peers.acquire(sessionIP(session))
+	if !ok {
+		log.Errorf("Failed to register peer: all %d slots are in use", len(peers.slots))
 
 		return
 	}
-	ip := [4]byte{}
-	for i := range ip {
-		ip[i] = byte(rand.Intn(256))
+	defer slot.release()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// a newer connection of the session took over, this one is stale
+	go func() {
+		select {
+		case <-slot.evicted:
+			if err := c.WriteJSON("maintenance", map[string]string{"message": sessionReplacedMessage}); err != nil {
+				log.Errorf("Failed to write JSON: %v", err)
+			}
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	// the client reconnects with this token to keep its engine address
+	if err := c.WriteJSON("session", map[string]string{"token": issueSessionToken(slot.addr.IP)}); err != nil {
+		log.Errorf("Failed to write JSON: %v", err)
+
+		return
 	}
-	index, _ := pool.TryGet()
-	ip[0] = index
-	defer pool.TryPut(index)
 
 	writeChannel, err := peerConnection.CreateDataChannel("write", &webrtc.DataChannelInit{
 		Ordered:        &f,
 		MaxRetransmits: &z,
 	})
 	if err != nil {
-		log.Errorf("Failed to creates a data channel: %v", err)
+		log.Errorf("Failed to create a data channel: %v", err)
 
 		return
 	}
@@ -335,14 +472,14 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 		if err != nil {
 			panic(err)
 		}
-		connections[index] = d
+		slot.setWriter(d)
 
 		rc, err := peerConnection.CreateDataChannel("read", &webrtc.DataChannelInit{
 			Ordered:        &f,
 			MaxRetransmits: &z,
 		})
 		if err != nil {
-			log.Errorf("Failed to creates a data channel: %v", err)
+			log.Errorf("Failed to create a data channel: %v", err)
 
 			return
 		}
@@ -352,7 +489,9 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 			if err != nil {
 				panic(err)
 			}
-			go ReadLoop(d, ip)
+			if slot.retain() {
+				go ReadLoop(d, slot)
+			}
 		})
 	})
 	defer writeChannel.Close()
@@ -377,8 +516,6 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 			if err := peerConnection.Close(); err != nil {
 				log.Errorf("Failed to close PeerConnection: %v", err)
 			}
-		case webrtc.PeerConnectionStateClosed:
-			signalPeerConnections()
 		default:
 		}
 	})
@@ -412,14 +549,20 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 		}
 	})
 
-	// Add our new PeerConnection to global list
-	state := peerConnectionState{peerConnection, c, DefaultSignalsCount}
+	// Add our new PeerConnection to global list, and remove it once the websocket is gone
+	state := newPeerConnectionState(peerConnection, c)
 	listLock.Lock()
-	peerConnections = append(peerConnections, &state)
+	peerConnections[state.id] = state
 	listLock.Unlock()
+	defer func() {
+		listLock.Lock()
+		delete(peerConnections, state.id)
+		listLock.Unlock()
+	}()
 
 	// Signal for the new PeerConnection
-	signalPeerConnections()
+	go state.runRenegotiations(done)
+	state.requestRenegotiation()
 
 	message := &websocketMessage{}
 	for {
@@ -463,13 +606,7 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 
 				return
 			}
-			listLock.Lock()
-			state.signalsCount -= 1
-			isNeedSignaling := state.signalsCount > 0
-			listLock.Unlock()
-			if isNeedSignaling {
-				signalPeerConnections()
-			}
+			state.answered()
 		default:
 			log.Errorf("unknown message: %+v", message)
 		}
@@ -499,164 +636,19 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, html)
 }
 
-// Config holds the application configuration
-type Config struct {
-	Engine struct {
-		Arguments string `env:"ENGINE_ARGS" required:"false"`
-		Console   string `env:"ENGINE_CONSOLE" required:"false"`
-		GameDir   string `env:"GAME_DIR" required:"true"`
-	}
-	Libraries struct {
-		Client           string `env:"CLIENT_WASM_PATH" required:"true"`
-		Server           string `env:"SERVER_WASM_PATH" required:"true"`
-		Menu             string `env:"MENU_WASM_PATH" required:"true"`
-		Extras           string `env:"EXTRAS_PATH" required:"true"`
-		Filesystem       string `env:"FILESYSTEM_WASM_PATH" required:"true"`
-		DynamicLibraries string `env:"DYNAMIC_LIBRARIES" required:"true"`
-		FilesMap         string `env:"FILES_MAP" required:"true"`
-	}
-}
-
-// EngineConfig holds the configuration for the Xash3D engine (JSON response)
-type EngineConfig struct {
-	Arguments        []string          `json:"arguments"`
-	Console          []string          `json:"console"`
-	GameDir          string            `json:"game_dir"`
-	Libraries        map[string]string `json:"libraries"`
-	DynamicLibraries []string          `json:"dynamic_libraries"`
-	FilesMap         map[string]string `json:"files_map"`
-}
-
-var (
-	appConfig        Config
-	engineConfigJSON []byte
-)
-
-// configHandler returns the pre-serialized engine configuration
-func configHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(engineConfigJSON)
-}
-
-// sliceArgs converts a comma-separated string into a slice of strings
-func sliceArgs(value string) []string {
-	if value == "" {
-		return []string{}
-	}
-	parts := strings.Split(value, ",")
-	result := make([]string, 0, len(parts))
-	for _, part := range parts {
-		if trimmed := strings.TrimSpace(part); trimmed != "" {
-			result = append(result, trimmed)
-		}
-	}
-	return result
-}
-
-// parseFilesMap converts "from:to,from:to" format into map[string]string
-func parseFilesMap(value string) map[string]string {
-	result := make(map[string]string)
-	if value == "" {
-		return result
-	}
-	pairs := strings.Split(value, ",")
-	for _, pair := range pairs {
-		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
-		if len(parts) == 2 {
-			result[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
-		}
-	}
-	return result
-}
-
-type Server struct {
-}
-
-var disabledXPoweredBy = false
-var xPoweredByValue = "yohimik"
-
-func init() {
-	// Load server configuration
-	disable, _ := os.LookupEnv("DISABLE_X_POWERED_BY")
-	if disable == "true" {
-		disabledXPoweredBy = true
-	}
-	xPoweredValue, has := os.LookupEnv("X_POWERED_BY_VALUE")
-	if has {
-		xPoweredByValue = xPoweredValue
-	}
-
-	// Load engine configuration using configor
-	if err := configor.Load(&appConfig); err != nil {
-		log.Errorf("Failed to load configuration: %v", err)
-		panic(err)
-	}
-
-	// Build and serialize the engine config JSON once
-	engineConfig := EngineConfig{
-		Arguments: sliceArgs(appConfig.Engine.Arguments),
-		Console:   sliceArgs(appConfig.Engine.Console),
-		GameDir:   appConfig.Engine.GameDir,
-		Libraries: map[string]string{
-			"client":     appConfig.Libraries.Client,
-			"server":     appConfig.Libraries.Server,
-			"extras":     appConfig.Libraries.Extras,
-			"menu":       appConfig.Libraries.Menu,
-			"filesystem": appConfig.Libraries.Filesystem,
-		},
-		DynamicLibraries: sliceArgs(appConfig.Libraries.DynamicLibraries),
-		FilesMap:         parseFilesMap(appConfig.Libraries.FilesMap),
-	}
-
-	var err error
-	engineConfigJSON, err = json.Marshal(engineConfig)
-	if err != nil {
-		log.Errorf("Failed to serialize config: %v", err)
-		panic(err)
-	}
-}
-
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if !disabledXPoweredBy {
-		w.Header().Set("X-Powered-By", xPoweredByValue)
-	}
-	switch r.URL.Path {
-	case "/websocket":
-		websocketHandler(w, r)
-	case "/config":
-		configHandler(w, r)
-	default:
-		p := r.URL.Path
-		if r.URL.Path == "/" {
-			p = "index.html"
-		}
-		path := filepath.Join("public", p)
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			http.NotFound(w, r)
-			return
-		}
-		http.ServeFile(w, r, path)
-	}
-}
-
 func runSFU() {
 	settingEngine := webrtc.SettingEngine{}
 	settingEngine.DetachDataChannels()
 
-	port, ok := os.LookupEnv("PORT")
-	if ok {
-		p, err := strconv.Atoi(port)
-		if err == nil {
-			udpMux, err := ice.NewMultiUDPMuxFromPort(p)
-			if err != nil {
-				panic(err)
-			}
-			settingEngine.SetICEUDPMux(udpMux)
+	if port := appConfig().Network.Port; port != 0 {
+		udpMux, err := ice.NewMultiUDPMuxFromPort(port)
+		if err != nil {
+			panic(err)
 		}
+		settingEngine.SetICEUDPMux(udpMux)
 	}
 
-	ip, ok := os.LookupEnv("IP")
-	if ok {
+	if ip := appConfig().Network.IP; ip != "" {
 		settingEngine.SetNAT1To1IPs([]string{ip}, webrtc.ICECandidateTypeHost)
 	}
 
@@ -678,13 +670,19 @@ func runSFU() {
 
 	// request a keyframe every 3 seconds
 	go func() {
+		if !appConfig().Features.Voice {
+			return
+		}
 		for range time.NewTicker(time.Second * 3).C {
 			dispatchKeyFrame()
 		}
 	}()
 
-	// start HTTP server
-	if err := http.ListenAndServe(addr, &Server{}); err != nil { //nolint: gosec
-		log.Errorf("Failed to start http server: %v", err)
+	sfuReady.Store(true)
+
+	// start HTTP servers, the server is useless without its frontend
+	if err := serveHTTP(); err != nil {
+		log.Errorf("Failed to serve http: %v", err)
+		shutdown(1)
 	}
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/ice/v4"
+	"github.com/pion/webrtc/v4"
+)
+
+// chunkReader returns the packets of a fuzz input, each prefixed with its big-endian uint16 length,
+// one per read like a data channel
+type chunkReader struct {
+	data  []byte
+	reads int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.data) < 2 || r.reads == 100 {
+		return 0, io.EOF
+	}
+	size := min(int(binary.BigEndian.Uint16(r.data)), len(r.data)-2)
+	chunk := r.data[2 : 2+size]
+	r.data = r.data[2+size:]
+	r.reads++
+	return copy(p, chunk), nil
+}
+
+func FuzzReadLoop(f *testing.F) {
+	f.Add([]byte("\x00\x17\xff\xff\xff\xffgetchallenge steam\n\x00\x05\x01\x00\x00\x00\x02"))
+	f.Add([]byte("\x00\x00\x00\x03\xff\xff\xff\x00\x05\xff\xff\xff\xff\x00"))
+	f.Add(append([]byte{0x20, 0x00}, make([]byte, 8192)...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cfg := useTestConfig(t)
+		registry := &connectionRegistry{free: newIndexPool(1)}
+		slot, _ := registry.acquire([4]byte{10, 0, 0, 1})
+		capture := &packetCapture{packets: make(chan capturedPacket, 100)}
+		slot.capture.Store(capture)
+		for net.engineNet.RecvFrom() != nil {
+		}
+
+		ReadLoop(&chunkReader{data: data}, slot)
+
+		if registry.slot(slot.addr) != nil {
+			t.Fatal("slot still registered after the read loop ended")
+		}
+		pushed := 0
+		for packet := net.engineNet.RecvFrom(); packet != nil; packet = net.engineNet.RecvFrom() {
+			if packet.Addr != slot.addr {
+				t.Fatalf("packet pushed from %v, the peer is %v", packet.Addr, slot.addr)
+			}
+			if len(packet.Data) > cfg.Firewall.MaxPacketSize || malformedPacket(packet.Data) {
+				t.Fatalf("packet passed the firewall: %x", packet.Data)
+			}
+			pushed++
+		}
+		if len(capture.packets) != pushed {
+			t.Fatalf("captured %d packets, pushed %d", len(capture.packets), pushed)
+		}
+	})
+}
+
+func FuzzSignalingMessage(f *testing.F) {
+	f.Add(`{"event":"candidate","data":{"candidate":"candidate:1 1 udp 2130706431 10.0.0.1 50000 typ host","sdpMid":"0"}}`)
+	f.Add(`{"event":"answer","data":{"type":"answer","sdp":"v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n"}}`)
+	f.Add(`{"event":"challenge","data":{"challenge":"00ff","solution":"1"}}`)
+	f.Add(`{"event":"challenge","data":{"challenge":"00ff","solution":"1","session":"0a000001.9999999999.sig"}}`)
+	f.Add(`{"event":"challenge","data":"00ff"}`)
+	f.Add(`{"event":1}`)
+	f.Fuzz(func(t *testing.T, raw string) {
+		message := websocketMessage{}
+		if err := json.Unmarshal([]byte(raw), &message); err != nil {
+			return
+		}
+		switch message.Event {
+		case "candidate":
+			candidate := webrtc.ICECandidateInit{}
+			if err := json.Unmarshal(message.Data, &candidate); err != nil {
+				return
+			}
+			// AddICECandidate parses the candidate like this
+			ice.UnmarshalCandidate(strings.TrimPrefix(candidate.Candidate, "candidate:"))
+		case "answer":
+			answer := webrtc.SessionDescription{}
+			if err := json.Unmarshal(message.Data, &answer); err != nil {
+				return
+			}
+			answer.Unmarshal()
+		case "challenge":
+			request := challengeRequest{Challenge: "00ff", Bits: 4}
+			response, err := verifyChallenge(request, message)
+			if err != nil {
+				return
+			}
+			if response.Challenge != request.Challenge || leadingZeroBits(sha256.Sum256([]byte(request.Challenge+":"+response.Solution))) < request.Bits {
+				t.Fatalf("accepted challenge response %s", message.Data)
+			}
+		}
+	})
+}
+
+func TestRenegotiationsStopWhenClosed(t *testing.T) {
+	useTestConfig(t)
+	peerConnection, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerConnection.Close()
+	state := newPeerConnectionState(peerConnection, nil)
+
+	stopped := make(chan struct{})
+	go func() {
+		state.runRenegotiations(make(chan struct{}))
+		close(stopped)
+	}()
+	state.requestRenegotiation()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("renegotiations continued on a closed PeerConnection")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+)
+
+const shutdownMessage = "Server is shutting down"
+
+var shutdownOnce sync.Once
+
+// handleShutdownSignals shuts the server down gracefully on SIGTERM/SIGINT
+func handleShutdownSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	sig := <-signals
+	log.Infof("Received %v, shutting down", sig)
+	shutdown(0)
+}
+
+// shutdown stops admitting peers, notifies and closes connected ones, drains the
+// HTTP server and finally exits the process, which stops the engine.
+func shutdown(code int) {
+	shutdownOnce.Do(func() {
+		maintenance.set(true, shutdownMessage)
+
+		ctx, cancel := context.WithTimeout(context.Background(), appConfig().Server.ShutdownTimeout)
+		defer cancel()
+
+		closePeerConnections(shutdownMessage)
+
+		shutdownHTTP(ctx)
+
+		flushFileLogging()
+		os.Exit(code)
+	})
+}
+
+// closePeerConnections tells every peer why it is being disconnected and closes it
+func closePeerConnections(message string) {
+	listLock.RLock()
+	states := slices.Collect(maps.Values(peerConnections))
+	listLock.RUnlock()
+
+	for _, state := range states {
+		if err := state.websocket.WriteJSON("maintenance", map[string]string{"message": message}); err != nil {
+			log.Errorf("Failed to write JSON: %v", err)
+		}
+		if err := state.peerConnection.Close(); err != nil {
+			log.Errorf("Failed to close PeerConnection: %v", err)
+		}
+		if err := state.websocket.Close(); err != nil {
+			log.Errorf("Failed to close websocket: %v", err)
+		}
+	}
+}
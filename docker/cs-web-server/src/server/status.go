@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// engineStatus reports how the engine keeps up with its tick rate
+type engineStatus struct {
+	LastPoll *time.Time `json:"last_poll,omitempty"`
+	TickRate int        `json:"tick_rate"`
+	PollRate float64    `json:"poll_rate"`
+	// PollInterval approximates the frame times, the engine polls the network at least once per frame
+	PollInterval pollQuantiles `json:"poll_interval_seconds"`
+}
+
+type serverStatus struct {
+	Engine      engineStatus `json:"engine"`
+	Peers       int          `json:"peers"`
+	Maintenance bool         `json:"maintenance"`
+}
+
+// statusHandler reports the engine timing of the last 10 seconds, peers and maintenance mode,
+// telling engine-side lag apart from network-side lag without a Prometheus server
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status := serverStatus{
+		Engine: engineStatus{
+			TickRate:     tickRate(appConfig()),
+			PollRate:     achievedPollRate(),
+			PollInterval: lastPollQuantiles(),
+		},
+		Peers: activePeerCount(),
+	}
+	if last := lastEngineHeartbeat(); !last.IsZero() {
+		status.Engine.LastPoll = &last
+	}
+	_, status.Maintenance = maintenance.check()
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"golang.org/x/crypto/acme/autocert"
+	"net/http"
+)
+
+// tlsEnabled reports whether certificates are configured
+func tlsEnabled(cfg *Config) bool {
+	return cfg.TLS.CertFile != "" || cfg.TLS.Autocert.Domains != ""
+}
+
+// validateTLS rejects incomplete or conflicting certificate settings
+func validateTLS(cfg *Config) error {
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return fmt.Errorf("tls cert file and key file must be set together")
+	}
+	if cfg.TLS.CertFile != "" && cfg.TLS.Autocert.Domains != "" {
+		return fmt.Errorf("tls cert files and autocert domains are mutually exclusive")
+	}
+	for _, listener := range cfg.HTTP.Listeners {
+		if listener.TLS && !tlsEnabled(cfg) {
+			return fmt.Errorf("listener %s uses tls but no certificates are configured", listener.Address)
+		}
+	}
+	return nil
+}
+
+// newTLSConfig builds the TLS config shared by all TLS listeners.
+// With autocert it also returns the handler answering ACME HTTP-01 challenges.
+func newTLSConfig(cfg *Config) (*tls.Config, http.Handler, error) {
+	if cfg.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load tls certificate: %w", err)
+		}
+		return &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}, nil, nil
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(sliceArgs(cfg.TLS.Autocert.Domains)...),
+		Cache:      autocert.DirCache(cfg.TLS.Autocert.CacheDir),
+		Email:      cfg.TLS.Autocert.Email,
+	}
+	tlsConfig := m.TLSConfig()
+	tlsConfig.MinVersion = tls.VersionTLS12
+	return tlsConfig, m.HTTPHandler(nil), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+const (
+	// engineModule is the Go module of the engine bindings, its version pins the xash3d-fwgs build
+	engineModule = "github.com/yohimik/goxash3d-fwgs"
+
+	// The network protocols of the xash3d-fwgs engine: its own and the GoldSrc one native clients speak
+	xashProtocolVersion    = 49
+	goldSrcProtocolVersion = 48
+)
+
+type versionInfo struct {
+	Version   string         `json:"version"`
+	Commit    string         `json:"commit,omitempty"`
+	BuildTime string         `json:"build_time,omitempty"`
+	Modified  bool           `json:"modified,omitempty"`
+	Go        string         `json:"go"`
+	Platform  string         `json:"platform"`
+	Engine    string         `json:"engine"`
+	Protocols map[string]int `json:"protocols"`
+	Features  []string       `json:"features"`
+}
+
+// buildVersion reads the server version and VCS stamp that go build embeds into the binary
+func buildVersion() versionInfo {
+	info := versionInfo{
+		Version:   "unknown",
+		Go:        runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Engine:    engineVersion(),
+		Protocols: map[string]int{"xash": xashProtocolVersion, "goldsrc": goldSrcProtocolVersion},
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Version = build.Main.Version
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			info.BuildTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// moduleVersion returns the version of a dependency built into the binary
+func moduleVersion(path string) string {
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, module := range build.Deps {
+		if module.Path != path {
+			continue
+		}
+		if module.Replace != nil {
+			return module.Replace.Version
+		}
+		return module.Version
+	}
+	return "unknown"
+}
+
+// enabledFeatures lists the optional features the configuration turns on
+func enabledFeatures(cfg *Config) []string {
+	features := []string{}
+	add := func(name string, enabled bool) {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	add("tls", tlsEnabled(cfg))
+	add("autocert", cfg.TLS.Autocert.Domains != "")
+	add("h2c", cfg.HTTP.H2C)
+	add("challenge_pow", cfg.Signaling.ChallengeBits > 0)
+	add("persistent_sessions", cfg.Signaling.SessionSecret != "")
+	add("firewall", cfg.Firewall.MaxPacketSize > 0 || cfg.Firewall.PacketRate > 0)
+	add("fastdl", cfg.Content.FastDL.Dir != "")
+	add("log_file", cfg.Log.File != "")
+	add("metrics_auth", cfg.Metrics.Username != "")
+	add("cross_origin_isolation", cfg.Security.CrossOriginIsolation)
+	add("scheduled_restart", cfg.Server.RestartTime != "")
+	add("watchdog", cfg.Watchdog.Timeout > 0)
+	add("voice", cfg.Features.Voice)
+	add("admin", cfg.Features.Admin)
+	add("metrics", cfg.Features.Metrics)
+	return features
+}
+
+// versionHandler reports what is running, for bug reports and the admin panel
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	info := buildVersion()
+	info.Features = enabledFeatures(appConfig())
+	writeJSON(w, http.StatusOK, info)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// engineHeartbeat is the unix nano time of the last engine network poll
+	engineHeartbeat atomic.Int64
+	startedAt       = time.Now()
+)
+
+// lastEngineHeartbeat returns the time of the last engine frame, zero before the first one
+func lastEngineHeartbeat() time.Time {
+	nano := engineHeartbeat.Load()
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// runWatchdog exits the process with a crash report once the engine stops polling the network.
+// Checks start after the first heartbeat so long map loads during startup aren't reported.
+func runWatchdog() {
+	watchdogTimeout := appConfig().Watchdog.Timeout
+	if watchdogTimeout <= 0 {
+		return
+	}
+	for range time.NewTicker(watchdogTimeout / 4).C {
+		last := lastEngineHeartbeat()
+		if last.IsZero() {
+			continue
+		}
+		if since := time.Since(last); since > watchdogTimeout {
+			engineCrashed(fmt.Sprintf("engine heartbeat missed for %v", since.Round(time.Second)), 1)
+			return
+		}
+	}
+}
+
+// engineCrashed records a crash report and shuts the server down so the container restarts it.
+// The engine can't be restarted in-process: Host_Main keeps global state and runs once per process.
+func engineCrashed(reason string, code int) {
+	log.Errorf("Engine crashed: %s", reason)
+	if path, err := writeCrashReport(reason); err != nil {
+		log.Errorf("Failed to write crash report: %v", err)
+	} else {
+		log.Errorf("Crash report written to %s", path)
+	}
+	shutdown(code)
+}
+
+// writeCrashReport dumps the reason, runtime info and all goroutine stacks into the crash report dir
+func writeCrashReport(reason string) (string, error) {
+	crashReportDir := appConfig().Watchdog.CrashReportDir
+	if crashReportDir == "" {
+		return "", fmt.Errorf("crash reports are disabled")
+	}
+	if err := os.MkdirAll(crashReportDir, 0o755); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	path := filepath.Join(crashReportDir, fmt.Sprintf("crash-%s.txt", now.Format("20060102-150405")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	stack := make([]byte, 1<<20)
+	stack = stack[:runtime.Stack(stack, true)]
+
+	_, err = fmt.Fprintf(f, "reason: %s\ntime: %s\nuptime: %s\nlast engine heartbeat: %s\ngo: %s %s/%s\ngoroutines: %d\n\n%s",
+		reason,
+		now.Format(time.RFC3339),
+		now.Sub(startedAt).Round(time.Second),
+		lastEngineHeartbeat().Format(time.RFC3339Nano),
+		runtime.Version(), runtime.GOOS, runtime.GOARCH,
+		runtime.NumGoroutine(),
+		stack,
+	)
+	return path, err
+}